@@ -5,14 +5,21 @@ import (
 
 	"github.com/go-redis/redis/v8"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/montekkundan/bored/backend/config"
 	"github.com/montekkundan/bored/backend/db"
 	_ "github.com/montekkundan/bored/backend/docs"
 	"github.com/montekkundan/bored/backend/handlers"
+	"github.com/montekkundan/bored/backend/mailers"
 	"github.com/montekkundan/bored/backend/middlewares"
+	"github.com/montekkundan/bored/backend/oidc"
+	"github.com/montekkundan/bored/backend/passwords"
+	"github.com/montekkundan/bored/backend/policy"
 	"github.com/montekkundan/bored/backend/repositories"
 	"github.com/montekkundan/bored/backend/services"
+	"github.com/montekkundan/bored/backend/signing"
+	"github.com/montekkundan/bored/backend/sms"
 	fiberSwagger "github.com/swaggo/fiber-swagger"
 )
 
@@ -24,6 +31,31 @@ func main() {
 		Addr: fmt.Sprintf("%s:%s", envConfig.RedisHost, envConfig.RedisPort),
 	})
 
+	mailer, err := mailers.NewMailer(envConfig)
+	if err != nil {
+		log.Fatalf("Unable to configure mailer: %v", err)
+	}
+
+	smsSender, err := sms.NewSender(envConfig)
+	if err != nil {
+		log.Fatalf("Unable to configure sms sender: %v", err)
+	}
+
+	signingKeys, err := signing.LoadKeyManager(envConfig.JWTKeysDir, envConfig.JWTActiveKID)
+	if err != nil {
+		log.Fatalf("Unable to load jwt signing keys: %v", err)
+	}
+
+	passwordHasher, err := passwords.NewHasher(envConfig)
+	if err != nil {
+		log.Fatalf("Unable to configure password hasher: %v", err)
+	}
+
+	oidcProviders, err := oidc.LoadProviders(envConfig.OIDCProvidersFile)
+	if err != nil {
+		log.Fatalf("Unable to load oidc providers: %v", err)
+	}
+
 	app := fiber.New(fiber.Config{
 		AppName:      "Bored",
 		ServerHeader: "Fiber",
@@ -33,11 +65,15 @@ func main() {
 	app.Get("/swagger/*", fiberSwagger.WrapHandler)
 
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "http://localhost:3000",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-		AllowMethods: "GET, POST, PUT, DELETE, OPTIONS",
+		AllowOrigins:     "http://localhost:3000",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-CSRF-Token, X-Session-Mode",
+		AllowMethods:     "GET, POST, PUT, DELETE, OPTIONS",
+		AllowCredentials: true, // the refresh token cookie in web session mode
 	}))
 
+	// Public keys for verifying our tokens
+	handlers.NewJWKSHandler(app, signingKeys)
+
 	// Repositories
 	eventRepository := repositories.NewEventRepository(db)
 	ticketRepository := repositories.NewTicketRepository(db)
@@ -46,33 +82,58 @@ func main() {
 	chatRepository := repositories.NewChatRepository(db)
 	oauthProviderRepository := repositories.NewOAuthProviderRepository(db)
 	refreshTokenRepository := repositories.NewRefreshTokenRepository(db)
+	recoveryCodeRepository := repositories.NewRecoveryCodeRepository(db)
+	verificationTokenRepository := repositories.NewVerificationTokenRepository(db)
 	boringSpaceRepository := repositories.NewBoringSpaceRepository(db)
+	boringSpaceInvitationRepository := repositories.NewBoringSpaceInvitationRepository(db)
+	boringSpaceModerationRepository := repositories.NewBoringSpaceModerationRepository(db)
 	publicMessageRepository := repositories.NewPublicMessageRepository(db)
+	apiKeyRepository := repositories.NewAPIKeyRepository(db)
+	auditLogRepository := repositories.NewAuditLogRepository(db)
 
 	// Service
 	userService := services.NewUserService(userRepository)
-	authService := services.NewAuthService(authRepository, userService, *envConfig, refreshTokenRepository, redisClient)
+	auditService := services.NewAuditService(auditLogRepository)
+	authService := services.NewAuthService(authRepository, userService, *envConfig, refreshTokenRepository, recoveryCodeRepository, apiKeyRepository, verificationTokenRepository, mailer, smsSender, redisClient, signingKeys, passwordHasher, passwords.NewPolicy(envConfig), auditService)
 	notificationService := services.NewNotificationService(repositories.NewNotificationRepository(db))
 	moderationVoteService := services.NewModerationVoteService(repositories.NewModerationVoteRepository(db))
-	boringSpaceService := services.NewBoringSpaceService(boringSpaceRepository)
-	publicMessageService := services.NewPublicMessageService(publicMessageRepository)
+	boringSpaceService := services.NewBoringSpaceService(boringSpaceRepository, boringSpaceModerationRepository)
+	boringSpaceInvitationService := services.NewBoringSpaceInvitationService(boringSpaceInvitationRepository, boringSpaceRepository, boringSpaceModerationRepository, userService, notificationService)
+	boringSpaceModerationService := services.NewBoringSpaceModerationService(boringSpaceModerationRepository, boringSpaceRepository)
+	boringSpaceChannelService := services.NewBoringSpaceChannelService(chatRepository, boringSpaceService)
+	publicMessageService := services.NewPublicMessageService(publicMessageRepository, boringSpaceRepository)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepository, userService)
+	oauthService := services.NewOAuthService(oidcProviders, oauthProviderRepository, authRepository, authService, userService, redisClient, passwordHasher)
+
+	authz := policy.NewAuthorizer(userService, boringSpaceService)
 
 	// Routing
 	server := app.Group("/api")
-	handlers.NewAuthHandler(server.Group("/auth"), authService, userService)
+	authProtected := middlewares.AuthProtected(db, redisClient, signingKeys, auditService)
+	handlers.NewAuthHandler(server.Group("/auth"), authService, userService, auditService, envConfig, authProtected, authz)
+	handlers.NewOAuthProviderHandler(server.Group("/auth/oauth"), oauthService, auditService, envConfig, authProtected)
 
-	privateRoutes := server.Use(middlewares.AuthProtected(db))
+	// Everything below also accepts API keys, limited to the scope each group asks for
+	privateRoutes := server.Use(middlewares.APIKeyOrAuthProtected(authProtected, apiKeyService))
 
 	// Handlers
-	handlers.NewEventHandler(server.Group("/event"), eventRepository)
-	handlers.NewTicketHandler(privateRoutes.Group("/ticket"), ticketRepository)
-	handlers.NewChatHandler(privateRoutes.Group("/chat"), chatRepository)
-	handlers.NewOAuthProviderHandler(privateRoutes.Group("/oauth"), oauthProviderRepository)
-	handlers.NewNotificationHandler(privateRoutes.Group("/notifications"), notificationService)
-	handlers.NewModerationVoteHandler(privateRoutes.Group("/moderation"), moderationVoteService)
-	handlers.NewUserHandler(privateRoutes.Group("/users"), userService)
-	handlers.NewBoringSpaceHandler(privateRoutes.Group("/boringspaces"), boringSpaceService, userService)
-	handlers.NewPublicMessageHandler(privateRoutes.Group("/public-messages"), publicMessageService, userService)
+	handlers.NewEventHandler(server.Group("/event", middlewares.RequireScope("events")), eventRepository, authz)
+	handlers.NewTicketHandler(privateRoutes.Group("/ticket", middlewares.RequireScope("tickets")), ticketRepository)
+	handlers.NewChatHandler(privateRoutes.Group("/chat", middlewares.RequireScope("chats")), chatRepository)
+	handlers.NewNotificationHandler(privateRoutes.Group("/notifications", middlewares.RequireScope("notifications")), notificationService)
+	handlers.NewModerationVoteHandler(privateRoutes.Group("/moderation", middlewares.RequireScope("moderation")), moderationVoteService)
+	handlers.NewUserHandler(privateRoutes.Group("/users", middlewares.RequireScope("users")), userService, auditService, authz)
+	handlers.NewSessionHandler(privateRoutes.Group("/sessions", middlewares.RejectAPIKeys(), middlewares.RejectImpersonation()), authService, auditService)
+	handlers.NewAPIKeyHandler(privateRoutes.Group("/api-keys", middlewares.RejectAPIKeys(), middlewares.RejectImpersonation()), apiKeyService, auditService)
+	handlers.NewAuditLogHandler(privateRoutes.Group("/audit-logs", middlewares.RejectAPIKeys()), auditService, authz)
+	boringSpaceRoutes := privateRoutes.Group("/boringspaces", middlewares.RequireScope("boringspaces"))
+	handlers.NewBoringSpaceInvitationHandler(boringSpaceRoutes, boringSpaceInvitationService, boringSpaceService, authz)
+	handlers.NewBoringSpaceHandler(boringSpaceRoutes, boringSpaceService, userService, auditService, authz)
+	handlers.NewBoringSpacePostHandler(boringSpaceRoutes, publicMessageService, authz)
+	handlers.NewBoringSpaceChannelHandler(boringSpaceRoutes, boringSpaceChannelService, authz)
+	handlers.NewBoringSpaceEventHandler(boringSpaceRoutes, eventRepository, authz)
+	handlers.NewBoringSpaceModerationHandler(boringSpaceRoutes, boringSpaceModerationService, boringSpaceService, authz)
+	handlers.NewPublicMessageHandler(privateRoutes.Group("/public-messages", middlewares.RequireScope("public_messages")), publicMessageService, userService, authz)
 
 	app.Listen(fmt.Sprintf(":%s", envConfig.ServerPort))
 }
@@ -13,12 +13,48 @@ type EnvConfig struct {
 	DBUser             string `env:"DB_USER,required"`
 	DBPassword         string `env:"DB_PASSWORD,required"`
 	DBSSLMode          string `env:"DB_SSLMODE,required"`
-	AccessTokenSecret  string `env:"ACCESS_TOKEN_SECRET,required"`
-	RefreshTokenSecret string `env:"REFRESH_TOKEN_SECRET,required"`
 	AccessTokenExpiry  int    `env:"ACCESS_TOKEN_EXPIRY"`
 	RefreshTokenExpiry int    `env:"REFRESH_TOKEN_EXPIRY"`
+	JWTKeysDir         string `env:"JWT_KEYS_DIR"`
+	JWTActiveKID       string `env:"JWT_ACTIVE_KID"`
 	RedisHost          string `env:"REDIS_HOST,required"`
 	RedisPort          string `env:"REDIS_PORT,required"`
+	CookieDomain       string `env:"COOKIE_DOMAIN"`
+	CookieSecure       bool   `env:"COOKIE_SECURE" envDefault:"true"`
+	CookieSameSite     string `env:"COOKIE_SAMESITE" envDefault:"Strict"`
+	TwoFactorIssuer    string `env:"TWO_FACTOR_ISSUER" envDefault:"Bored"`
+	AppBaseURL         string `env:"APP_BASE_URL" envDefault:"http://localhost:3000"`
+
+	VerificationTokenSecret string `env:"VERIFICATION_TOKEN_SECRET,required"`
+	EmailVerificationExpiry int    `env:"EMAIL_VERIFICATION_EXPIRY" envDefault:"1440"`
+	PasswordResetExpiry     int    `env:"PASSWORD_RESET_EXPIRY" envDefault:"60"`
+
+	Mailer       string `env:"MAILER" envDefault:"file"`
+	MailFrom     string `env:"MAIL_FROM" envDefault:"no-reply@bored.rocks"`
+	MailDir      string `env:"MAIL_DIR" envDefault:"tmp/mail"`
+	SMTPHost     string `env:"SMTP_HOST"`
+	SMTPPort     string `env:"SMTP_PORT" envDefault:"587"`
+	SMTPUsername string `env:"SMTP_USERNAME"`
+	SMTPPassword string `env:"SMTP_PASSWORD"`
+
+	SMSSender string `env:"SMS_SENDER" envDefault:"log"`
+
+	OIDCProvidersFile string `env:"OIDC_PROVIDERS_FILE"`
+
+	PasswordHasher    string `env:"PASSWORD_HASHER" envDefault:"argon2id"`
+	Argon2Memory      int    `env:"ARGON2_MEMORY" envDefault:"65536"`
+	Argon2Iterations  int    `env:"ARGON2_ITERATIONS" envDefault:"3"`
+	Argon2Parallelism int    `env:"ARGON2_PARALLELISM" envDefault:"2"`
+	BcryptCost        int    `env:"BCRYPT_COST" envDefault:"12"`
+	PasswordMinLength int    `env:"PASSWORD_MIN_LENGTH" envDefault:"10"`
+	PasswordMaxLength int    `env:"PASSWORD_MAX_LENGTH" envDefault:"128"`
+
+	LockoutThreshold    int `env:"LOCKOUT_THRESHOLD" envDefault:"5"`
+	IPLockoutThreshold  int `env:"IP_LOCKOUT_THRESHOLD" envDefault:"20"`
+	LockoutBaseDuration int `env:"LOCKOUT_BASE_DURATION" envDefault:"1"`
+	LockoutMaxDuration  int `env:"LOCKOUT_MAX_DURATION" envDefault:"1440"`
+
+	ImpersonationTokenExpiry int `env:"IMPERSONATION_TOKEN_EXPIRY" envDefault:"15"` // minutes
 }
 
 func NewEnvConfig() *EnvConfig {
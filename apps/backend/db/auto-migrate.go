@@ -6,7 +6,15 @@ import (
 )
 
 func DBMigrator(db *gorm.DB) error {
-	return db.AutoMigrate(
+	// Refresh tokens used to be stored in plain text. Old rows can't be given the hash and family
+	// the new NOT NULL columns need, so the table is rebuilt and everyone signs in again.
+	if db.Migrator().HasColumn(&models.RefreshToken{}, "token") {
+		if err := db.Migrator().DropTable(&models.RefreshToken{}); err != nil {
+			return err
+		}
+	}
+
+	if err := db.AutoMigrate(
 		&models.Event{},
 		&models.Ticket{},
 		&models.User{},
@@ -16,9 +24,38 @@ func DBMigrator(db *gorm.DB) error {
 		&models.Message{},
 		&models.ModerationVote{},
 		&models.RefreshToken{},
+		&models.RecoveryCode{},
+		&models.VerificationToken{},
+		&models.APIKey{},
+		&models.AuditLog{},
 		&models.PublicMessage{},
 		&models.Comment{},
 		&models.BoringSpace{},
 		&models.BoringSpaceMember{},
-	)
+		&models.BoringSpaceRoleDefinition{},
+		&models.BoringSpaceInvitation{},
+		&models.BoringSpaceInviteCode{},
+		&models.BoringSpaceJoinRequest{},
+		&models.BoringSpaceSanction{},
+		&models.BoringSpaceModerationLog{},
+	); err != nil {
+		return err
+	}
+
+	// The audit log is append-only, enforce it below the application too
+	for _, stmt := range []string{
+		`CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_logs is append-only';
+		END;
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS audit_logs_append_only ON audit_logs`,
+		`CREATE TRIGGER audit_logs_append_only BEFORE UPDATE OR DELETE ON audit_logs FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only()`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
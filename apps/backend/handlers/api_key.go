@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/models"
+	"gorm.io/gorm"
+)
+
+type APIKeyHandler struct {
+	service models.APIKeyService
+	audit   models.AuditService
+}
+
+func (h *APIKeyHandler) CreateKey(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	req := &models.CreateAPIKeyRequest{}
+
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
+	defer cancel()
+
+	if err := ctx.BodyParser(req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "please provide a name, at least one scope and an expiry of at most 365 days",
+		})
+	}
+
+	rawKey, key, err := h.service.CreateKey(context, userID, req)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	recordAudit(ctx, h.audit, models.AuditAPIKeyCreated, userID, map[string]interface{}{"api_key_id": key.ID, "name": key.Name, "scopes": key.Scopes})
+
+	return ctx.Status(fiber.StatusCreated).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "Store this key now, it will not be shown again",
+		"data": &fiber.Map{
+			"key":     rawKey,
+			"api_key": key,
+		},
+	})
+}
+
+func (h *APIKeyHandler) GetKeys(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	keys, err := h.service.GetKeys(context.Background(), userID)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not retrieve api keys"})
+	}
+
+	return ctx.JSON(fiber.Map{"status": "success", "data": keys})
+}
+
+func (h *APIKeyHandler) RevokeKey(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	keyID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "fail", "message": "Invalid api key ID"})
+	}
+
+	if err := h.service.RevokeKey(context.Background(), userID, uint(keyID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "fail", "message": "API key not found"})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not revoke api key"})
+	}
+
+	recordAudit(ctx, h.audit, models.AuditAPIKeyRevoked, userID, map[string]interface{}{"api_key_id": keyID})
+
+	return ctx.JSON(fiber.Map{"status": "success", "message": "API key revoked"})
+}
+
+func NewAPIKeyHandler(route fiber.Router, service models.APIKeyService, audit models.AuditService) {
+	handler := &APIKeyHandler{service: service, audit: audit}
+
+	route.Get("/", handler.GetKeys)
+	route.Post("/", handler.CreateKey)
+	route.Delete("/:id", handler.RevokeKey)
+}
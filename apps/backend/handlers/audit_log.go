@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/policy"
+)
+
+type AuditLogHandler struct {
+	service models.AuditService
+}
+
+// GetAuditLogs lets admins search the whole log, e.g. ?user_id=4&type=login_failed&from=2024-06-01T00:00:00Z
+func (h *AuditLogHandler) GetAuditLogs(ctx *fiber.Ctx) error {
+	filter := &models.AuditLogFilter{
+		Type:   models.AuditEventType(ctx.Query("type")),
+		Limit:  ctx.QueryInt("limit"),
+		Offset: ctx.QueryInt("offset"),
+	}
+
+	if userID := ctx.Query("user_id"); userID != "" {
+		id, err := strconv.ParseUint(userID, 10, 32)
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"status": "fail", "message": "Invalid user ID format"})
+		}
+		uid := uint(id)
+		filter.UserID = &uid
+	}
+
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := ctx.Query(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"status": "fail", "message": "Invalid " + param + " time, expected RFC 3339"})
+			}
+			*target = &t
+		}
+	}
+
+	entries, err := h.service.Query(context.Background(), filter)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{"status": "error", "message": "Could not retrieve audit logs"})
+	}
+
+	return ctx.JSON(&fiber.Map{"status": "success", "data": entries})
+}
+
+// GetMyActivity is the "recent security activity" view of the signed in user's own account
+func (h *AuditLogHandler) GetMyActivity(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	entries, err := h.service.RecentActivity(context.Background(), userID, ctx.QueryInt("limit", 20))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{"status": "error", "message": "Could not retrieve security activity"})
+	}
+
+	return ctx.JSON(&fiber.Map{"status": "success", "data": entries})
+}
+
+// recordAudit logs an action taken by the signed in user, if any, against targetID (0 for none)
+func recordAudit(ctx *fiber.Ctx, audit models.AuditService, eventType models.AuditEventType, targetID uint, metadata map[string]interface{}) {
+	entry := &models.AuditLog{
+		Type:      eventType,
+		IPAddress: ctx.IP(),
+		UserAgent: ctx.Get(fiber.HeaderUserAgent),
+		Metadata:  metadata,
+	}
+	if actorID, ok := ctx.Locals("userId").(uint); ok {
+		entry.ActorID = &actorID
+	}
+	// The admin is the one really acting
+	if adminID, ok := ctx.Locals("impersonatorId").(uint); ok {
+		if entry.Metadata == nil {
+			entry.Metadata = map[string]interface{}{}
+		}
+		entry.Metadata["impersonated_user_id"] = *entry.ActorID
+		entry.ActorID = &adminID
+	}
+	if targetID != 0 {
+		entry.TargetID = &targetID
+	}
+	audit.Record(ctx.Context(), entry)
+}
+
+func NewAuditLogHandler(route fiber.Router, service models.AuditService, authz *policy.Authorizer) {
+	handler := &AuditLogHandler{service: service}
+
+	route.Get("/", authz.RequireRole(models.Admin), handler.GetAuditLogs)
+	route.Get("/me", handler.GetMyActivity)
+}
@@ -2,13 +2,21 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/config"
+	"github.com/montekkundan/bored/backend/middlewares"
 	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/policy"
+	"gorm.io/gorm"
 )
 
 var validate = validator.New()
@@ -16,6 +24,8 @@ var validate = validator.New()
 type AuthHandler struct {
 	authService models.AuthService
 	userService models.UserService
+	audit       models.AuditService
+	config      *config.EnvConfig
 }
 
 // @Summary Login
@@ -48,7 +58,22 @@ func (h *AuthHandler) Login(ctx *fiber.Ctx) error {
 		})
 	}
 
-	token, user, err := h.authService.Login(context, creds)
+	token, user, err := h.authService.Login(context, creds, deviceInfo(ctx))
+
+	var lockout *models.LockoutError
+	if errors.As(err, &lockout) {
+		return lockedOut(ctx, lockout)
+	}
+
+	if errors.Is(err, models.ErrTwoFactorRequired) {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+			"data": &fiber.Map{
+				"two_factor_required": true,
+			},
+		})
+	}
 
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
@@ -57,6 +82,14 @@ func (h *AuthHandler) Login(ctx *fiber.Ctx) error {
 		})
 	}
 
+	token, err = sessionResponse(ctx, h.config, token)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+			"status":  "error",
+			"message": "Failed to start session",
+		})
+	}
+
 	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
 		"status":  "success",
 		"message": "Successfully logged in",
@@ -105,7 +138,7 @@ func (h *AuthHandler) Register(ctx *fiber.Ctx) error {
 		})
 	}
 
-	token, user, err := h.authService.Register(context, creds)
+	token, user, err := h.authService.Register(context, creds, deviceInfo(ctx))
 
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
@@ -114,6 +147,16 @@ func (h *AuthHandler) Register(ctx *fiber.Ctx) error {
 		})
 	}
 
+	token, err = sessionResponse(ctx, h.config, token)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+			"status":  "error",
+			"message": "Failed to start session",
+		})
+	}
+
+	recordAudit(ctx, h.audit, models.AuditRegistered, user.ID, nil)
+
 	return ctx.Status(fiber.StatusCreated).JSON(&fiber.Map{
 		"status":  "success",
 		"message": "Successfully registered",
@@ -126,7 +169,7 @@ func (h *AuthHandler) Register(ctx *fiber.Ctx) error {
 
 func (h *AuthHandler) VerifyEmail(ctx *fiber.Ctx) error {
 	var payload struct {
-		UserID uint `json:"user_id" validate:"required"`
+		Token string `json:"token" validate:"required"`
 	}
 
 	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
@@ -139,7 +182,14 @@ func (h *AuthHandler) VerifyEmail(ctx *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.authService.VerifyEmail(context, payload.UserID); err != nil {
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "please provide a verification token",
+		})
+	}
+
+	if err := h.authService.VerifyEmail(context, payload.Token, deviceInfo(ctx)); err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
 			"status":  "fail",
 			"message": err.Error(),
@@ -152,10 +202,207 @@ func (h *AuthHandler) VerifyEmail(ctx *fiber.Ctx) error {
 	})
 }
 
+func (h *AuthHandler) ResendVerificationEmail(ctx *fiber.Ctx) error {
+	var payload struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
+	defer cancel()
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "please provide a valid email",
+		})
+	}
+
+	if err := h.authService.ResendVerificationEmail(context, payload.Email); err != nil {
+		if errors.Is(err, models.ErrTooManyRequests) {
+			return ctx.Status(fiber.StatusTooManyRequests).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": err.Error(),
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "Could not send verification email",
+		})
+	}
+
+	// Same answer whether or not the address exists
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "If the account exists and is not verified, a new verification email has been sent",
+	})
+}
+
+func (h *AuthHandler) ForgotPassword(ctx *fiber.Ctx) error {
+	var payload struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
+	defer cancel()
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "please provide a valid email",
+		})
+	}
+
+	if err := h.authService.ForgotPassword(context, payload.Email, deviceInfo(ctx)); err != nil {
+		if errors.Is(err, models.ErrTooManyRequests) {
+			return ctx.Status(fiber.StatusTooManyRequests).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": err.Error(),
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "Could not send password reset email",
+		})
+	}
+
+	// Same answer whether or not the address exists
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "If the account exists, a password reset email has been sent",
+	})
+}
+
+func (h *AuthHandler) ResetPassword(ctx *fiber.Ctx) error {
+	var payload struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
+	defer cancel()
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "please provide a reset token and a new password",
+		})
+	}
+
+	if err := h.authService.ResetPassword(context, payload.Token, payload.Password, deviceInfo(ctx)); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "Password reset successfully, please log in again",
+	})
+}
+
+func (h *AuthHandler) ChangePassword(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	var payload struct {
+		CurrentPassword string `json:"current_password" validate:"required"`
+		NewPassword     string `json:"new_password" validate:"required"`
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
+	defer cancel()
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "please provide your current password and a new password",
+		})
+	}
+
+	if err := h.authService.ChangePassword(context, userID, payload.CurrentPassword, payload.NewPassword); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	recordAudit(ctx, h.audit, models.AuditPasswordChanged, userID, nil)
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "Password changed successfully, please log in again",
+	})
+}
+
+func (h *AuthHandler) RequestPhoneVerification(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	var payload struct {
+		PhoneNumber string `json:"phone_number"`
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
+	defer cancel()
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	if err := h.authService.RequestPhoneVerification(context, userID, payload.PhoneNumber); err != nil {
+		if errors.Is(err, models.ErrTooManyRequests) {
+			return ctx.Status(fiber.StatusTooManyRequests).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": err.Error(),
+			})
+		}
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "Verification code sent",
+	})
+}
+
 func (h *AuthHandler) VerifyPhoneNumber(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
 	var payload struct {
-		UserID uint   `json:"user_id" validate:"required"`
-		Code   string `json:"code" validate:"required"`
+		Code string `json:"code" validate:"required"`
 	}
 
 	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
@@ -168,22 +415,58 @@ func (h *AuthHandler) VerifyPhoneNumber(ctx *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.authService.VerifyPhoneNumber(context, payload.UserID, payload.Code); err != nil {
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "please provide a code",
+		})
+	}
+
+	if err := h.authService.VerifyPhoneNumber(context, userID, payload.Code); err != nil {
+		var lockout *models.LockoutError
+		if errors.As(err, &lockout) {
+			return lockedOut(ctx, lockout)
+		}
 		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
 			"status":  "fail",
 			"message": err.Error(),
 		})
 	}
 
+	recordAudit(ctx, h.audit, models.AuditPhoneVerified, userID, nil)
+
 	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
 		"status":  "success",
 		"message": "Phone number verified successfully",
 	})
 }
 
-func (h *AuthHandler) EnableTwoFactor(ctx *fiber.Ctx) error {
+func (h *AuthHandler) SetupTwoFactor(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
+	defer cancel()
+
+	setup, err := h.authService.SetupTwoFactor(context, userID)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "Scan the QR code and confirm with a code from your authenticator app",
+		"data":    setup,
+	})
+}
+
+func (h *AuthHandler) ConfirmTwoFactor(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
 	var payload struct {
-		UserID uint `json:"user_id" validate:"required"`
+		Code string `json:"code" validate:"required"`
 	}
 
 	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
@@ -196,21 +479,183 @@ func (h *AuthHandler) EnableTwoFactor(ctx *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.authService.EnableTwoFactor(context, payload.UserID); err != nil {
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "please provide a code",
+		})
+	}
+
+	recoveryCodes, err := h.authService.ConfirmTwoFactor(context, userID, payload.Code)
+	if err != nil {
+		var lockout *models.LockoutError
+		if errors.As(err, &lockout) {
+			return lockedOut(ctx, lockout)
+		}
 		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
 			"status":  "fail",
 			"message": err.Error(),
 		})
 	}
 
+	recordAudit(ctx, h.audit, models.AuditTwoFactorEnabled, userID, nil)
+
 	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
 		"status":  "success",
 		"message": "Two-factor authentication enabled successfully",
+		"data": &fiber.Map{
+			"recovery_codes": recoveryCodes,
+		},
+	})
+}
+
+func (h *AuthHandler) DisableTwoFactor(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	var payload struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
+	defer cancel()
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "please provide a code",
+		})
+	}
+
+	if err := h.authService.DisableTwoFactor(context, userID, payload.Code); err != nil {
+		var lockout *models.LockoutError
+		if errors.As(err, &lockout) {
+			return lockedOut(ctx, lockout)
+		}
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	recordAudit(ctx, h.audit, models.AuditTwoFactorDisabled, userID, nil)
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "Two-factor authentication disabled successfully",
+	})
+}
+
+func (h *AuthHandler) RegenerateRecoveryCodes(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	var payload struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
+	defer cancel()
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "please provide a code",
+		})
+	}
+
+	recoveryCodes, err := h.authService.RegenerateRecoveryCodes(context, userID, payload.Code)
+	if err != nil {
+		var lockout *models.LockoutError
+		if errors.As(err, &lockout) {
+			return lockedOut(ctx, lockout)
+		}
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	recordAudit(ctx, h.audit, models.AuditRecoveryCodesRegenerated, userID, nil)
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "Recovery codes regenerated",
+		"data": &fiber.Map{
+			"recovery_codes": recoveryCodes,
+		},
+	})
+}
+
+// CompleteTwoFactorChallenge finishes a social login that was held back for a 2FA code
+func (h *AuthHandler) CompleteTwoFactorChallenge(ctx *fiber.Ctx) error {
+	var payload struct {
+		Challenge string `json:"challenge" validate:"required"`
+		Code      string `json:"code" validate:"required"`
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
+	defer cancel()
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "please provide a challenge and a code",
+		})
+	}
+
+	token, user, err := h.authService.CompleteTwoFactorChallenge(context, payload.Challenge, payload.Code, deviceInfo(ctx))
+	if err != nil {
+		var lockout *models.LockoutError
+		if errors.As(err, &lockout) {
+			return lockedOut(ctx, lockout)
+		}
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	token, err = sessionResponse(ctx, h.config, token)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+			"status":  "error",
+			"message": "Failed to start session",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "Successfully logged in",
+		"data": &fiber.Map{
+			"token": token,
+			"user":  user,
+		},
 	})
 }
 
 func (h *AuthHandler) Logout(ctx *fiber.Ctx) error {
-	refreshToken := ctx.Cookies("refresh_token")
+	refreshToken := refreshTokenFrom(ctx)
+	clearSessionCookies(ctx, h.config)
 	if refreshToken == "" {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
 			"status":  "fail",
@@ -218,7 +663,7 @@ func (h *AuthHandler) Logout(ctx *fiber.Ctx) error {
 		})
 	}
 
-	err := h.authService.Logout(ctx.Context(), refreshToken)
+	err := h.authService.Logout(ctx.Context(), refreshToken, deviceInfo(ctx))
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
 			"status":  "fail",
@@ -226,14 +671,43 @@ func (h *AuthHandler) Logout(ctx *fiber.Ctx) error {
 		})
 	}
 
+	// Also revoke the access token the client is still holding, if any
+	if parts := strings.Split(ctx.Get("Authorization"), " "); len(parts) == 2 && parts[0] == "Bearer" {
+		if err := h.authService.RevokeAccessToken(ctx.Context(), parts[1]); err != nil {
+			return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "Logout failed",
+			})
+		}
+	}
+
 	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
 		"status":  "success",
 		"message": "Logged out successfully",
 	})
 }
 
+func (h *AuthHandler) LogoutAll(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	if err := h.authService.LogoutAll(ctx.Context(), userID); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "Logout failed",
+		})
+	}
+	clearSessionCookies(ctx, h.config)
+
+	recordAudit(ctx, h.audit, models.AuditLogoutAll, userID, nil)
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "Logged out of all sessions",
+	})
+}
+
 func (h *AuthHandler) RotateRefreshToken(ctx *fiber.Ctx) error {
-	refreshToken := ctx.Cookies("refresh_token")
+	refreshToken := refreshTokenFrom(ctx)
 	if refreshToken == "" {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
 			"status":  "fail",
@@ -241,14 +715,24 @@ func (h *AuthHandler) RotateRefreshToken(ctx *fiber.Ctx) error {
 		})
 	}
 
-	tokens, err := h.authService.RotateRefreshToken(ctx.Context(), refreshToken)
+	tokens, err := h.authService.RotateRefreshToken(ctx.Context(), refreshToken, deviceInfo(ctx))
 	if err != nil {
+		// The cookie is dead either way, don't let the browser keep replaying it
+		clearSessionCookies(ctx, h.config)
 		return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
 			"status":  "fail",
 			"message": err.Error(),
 		})
 	}
 
+	tokens, err = sessionResponse(ctx, h.config, tokens)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+			"status":  "error",
+			"message": "Failed to refresh session",
+		})
+	}
+
 	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
 		"status":  "success",
 		"message": "Tokens refreshed",
@@ -298,18 +782,191 @@ func (h *AuthHandler) GetMe(ctx *fiber.Ctx) error {
 	})
 }
 
-func NewAuthHandler(route fiber.Router, authService models.AuthService, userService models.UserService) {
+func (h *AuthHandler) UnlockAccount(ctx *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "Invalid user ID format",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
+	defer cancel()
+
+	if err := h.authService.UnlockAccount(context, uint(userID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "User not found",
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+			"status":  "error",
+			"message": "Failed to unlock account",
+		})
+	}
+
+	recordAudit(ctx, h.audit, models.AuditAccountUnlocked, uint(userID), nil)
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "Account unlocked",
+	})
+}
+
+func (h *AuthHandler) UnlockIP(ctx *fiber.Ctx) error {
+	ip := net.ParseIP(ctx.Params("ip"))
+	if ip == nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "Invalid IP address",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
+	defer cancel()
+
+	if err := h.authService.UnlockIP(context, ip.String()); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+			"status":  "error",
+			"message": "Failed to unlock IP address",
+		})
+	}
+
+	recordAudit(ctx, h.audit, models.AuditAccountUnlocked, 0, map[string]interface{}{"ip": ip.String()})
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "IP address unlocked",
+	})
+}
+
+// Impersonate hands an admin a short-lived token to act as another user, e.g. POST /admin/users/4/impersonate {"reason": "ticket #123"}
+func (h *AuthHandler) Impersonate(ctx *fiber.Ctx) error {
+	adminID := ctx.Locals("userId").(uint)
+
+	targetID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "Invalid user ID format",
+		})
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&input); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "Invalid input",
+			})
+		}
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
+	defer cancel()
+
+	token, err := h.authService.Impersonate(context, adminID, uint(targetID), input.Reason, deviceInfo(ctx))
+	if err != nil {
+		if errors.Is(err, models.ErrImpersonationNotAllowed) {
+			return ctx.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": err.Error(),
+			})
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "User not found",
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+			"status":  "error",
+			"message": "Failed to start impersonation",
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(&fiber.Map{
+		"status": "success",
+		"data":   token,
+	})
+}
+
+// EndImpersonation revokes the impersonation token it is called with
+func (h *AuthHandler) EndImpersonation(ctx *fiber.Ctx) error {
+	adminID, ok := ctx.Locals("impersonatorId").(uint)
+	if !ok {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": models.ErrNotImpersonating.Error(),
+		})
+	}
+
+	err := h.authService.EndImpersonation(ctx.Context(), ctx.Locals("sessionId").(string), adminID, ctx.Locals("userId").(uint), deviceInfo(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+			"status":  "error",
+			"message": "Failed to end impersonation",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "Impersonation ended",
+	})
+}
+
+// lockedOut tells the client how long to back off for
+func lockedOut(ctx *fiber.Ctx, lockout *models.LockoutError) error {
+	ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
+	return ctx.Status(fiber.StatusTooManyRequests).JSON(&fiber.Map{
+		"status":  "fail",
+		"message": lockout.Error(),
+		"data": &fiber.Map{
+			"retry_after": int(math.Ceil(lockout.RetryAfter.Seconds())),
+		},
+	})
+}
+
+// deviceInfo captures the client details stored with each session
+func deviceInfo(ctx *fiber.Ctx) *models.DeviceInfo {
+	return &models.DeviceInfo{
+		UserAgent: ctx.Get(fiber.HeaderUserAgent),
+		IPAddress: ctx.IP(),
+	}
+}
+
+func NewAuthHandler(route fiber.Router, authService models.AuthService, userService models.UserService, audit models.AuditService, config *config.EnvConfig, authProtected fiber.Handler, authz *policy.Authorizer) {
 	handler := &AuthHandler{
 		authService: authService,
 		userService: userService,
+		audit:       audit,
+		config:      config,
 	}
 
 	route.Post("/login", handler.Login)
 	route.Post("/register", handler.Register)
 	route.Post("/verify-email", handler.VerifyEmail)
-	route.Post("/verify-phone", handler.VerifyPhoneNumber)
-	route.Post("/enable-2fa", handler.EnableTwoFactor)
-	route.Post("/logout", handler.Logout)
-	route.Post("/rotate-token", handler.RotateRefreshToken)
-	route.Get("/me", handler.GetMe)
+	route.Post("/resend-verification", handler.ResendVerificationEmail)
+	route.Post("/forgot-password", handler.ForgotPassword)
+	route.Post("/reset-password", handler.ResetPassword)
+	route.Post("/change-password", authProtected, middlewares.RejectImpersonation(), handler.ChangePassword)
+	route.Post("/phone/request-code", authProtected, middlewares.RejectImpersonation(), handler.RequestPhoneVerification)
+	route.Post("/verify-phone", authProtected, middlewares.RejectImpersonation(), handler.VerifyPhoneNumber)
+	route.Post("/2fa/setup", authProtected, middlewares.RejectImpersonation(), handler.SetupTwoFactor)
+	route.Post("/2fa/confirm", authProtected, middlewares.RejectImpersonation(), handler.ConfirmTwoFactor)
+	route.Post("/2fa/disable", authProtected, middlewares.RejectImpersonation(), handler.DisableTwoFactor)
+	route.Post("/2fa/recovery-codes", authProtected, middlewares.RejectImpersonation(), handler.RegenerateRecoveryCodes)
+	route.Post("/2fa/challenge", handler.CompleteTwoFactorChallenge)
+	route.Post("/logout", middlewares.CSRFProtected(), handler.Logout)
+	route.Post("/logout-all", authProtected, middlewares.RejectImpersonation(), handler.LogoutAll)
+	route.Post("/rotate-token", middlewares.CSRFProtected(), handler.RotateRefreshToken)
+	route.Get("/me", authProtected, handler.GetMe)
+	route.Post("/admin/users/:id/unlock", authProtected, authz.RequireRole(models.Admin), handler.UnlockAccount)
+	route.Post("/admin/ips/:ip/unlock", authProtected, authz.RequireRole(models.Admin), handler.UnlockIP)
+	route.Post("/admin/users/:id/impersonate", authProtected, middlewares.RejectImpersonation(), authz.RequireRole(models.Admin), handler.Impersonate)
+	route.Post("/impersonation/end", authProtected, handler.EndImpersonation)
 }
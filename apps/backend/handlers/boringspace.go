@@ -2,23 +2,29 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/policy"
+	"gorm.io/gorm"
 )
 
 type BoringSpaceHandler struct {
 	service     models.BoringSpaceService
 	userService models.UserService
+	audit       models.AuditService
+	authz       *policy.Authorizer
 }
 
 func (h *BoringSpaceHandler) CreateBoringSpace(ctx *fiber.Ctx) error {
 	userID := ctx.Locals("userId").(uint)
 
 	var input struct {
-		Name        string `json:"name" validate:"required"`
-		Description string `json:"description"`
+		Name        string                       `json:"name" validate:"required"`
+		Description string                       `json:"description"`
+		Visibility  models.BoringSpaceVisibility `json:"visibility"` // defaults to public
 	}
 
 	if err := ctx.BodyParser(&input); err != nil {
@@ -28,9 +34,20 @@ func (h *BoringSpaceHandler) CreateBoringSpace(ctx *fiber.Ctx) error {
 		})
 	}
 
+	if input.Visibility == "" {
+		input.Visibility = models.SpacePublic
+	}
+	if !input.Visibility.IsValid() {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Visibility must be public, unlisted or private",
+		})
+	}
+
 	space := &models.BoringSpace{
 		Name:        input.Name,
 		Description: input.Description,
+		Visibility:  input.Visibility,
 		CreatorID:   userID,
 	}
 
@@ -47,6 +64,38 @@ func (h *BoringSpaceHandler) CreateBoringSpace(ctx *fiber.Ctx) error {
 	})
 }
 
+// GetBoringSpaces is the directory of public spaces, e.g. ?q=chess&sort=members&limit=20&offset=40
+func (h *BoringSpaceHandler) GetBoringSpaces(ctx *fiber.Ctx) error {
+	filter := &models.BoringSpaceFilter{
+		Query:  ctx.Query("q"),
+		Sort:   models.BoringSpaceSort(ctx.Query("sort", string(models.SortByActivity))),
+		Limit:  ctx.QueryInt("limit"),
+		Offset: ctx.QueryInt("offset"),
+	}
+
+	switch filter.Sort {
+	case models.SortByMembers, models.SortByActivity, models.SortByNewest:
+	default:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Sort must be members, activity or newest",
+		})
+	}
+
+	spaces, err := h.service.Directory(context.Background(), filter)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve BoringSpaces",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status": "success",
+		"data":   spaces,
+	})
+}
+
 func (h *BoringSpaceHandler) GetBoringSpaceByID(ctx *fiber.Ctx) error {
 	spaceIDStr := ctx.Params("id")
 	spaceID, err := strconv.ParseUint(spaceIDStr, 10, 32)
@@ -71,45 +120,79 @@ func (h *BoringSpaceHandler) GetBoringSpaceByID(ctx *fiber.Ctx) error {
 	})
 }
 
-func (h *BoringSpaceHandler) AddMember(ctx *fiber.Ctx) error {
-	userID := ctx.Locals("userId").(uint)
+func (h *BoringSpaceHandler) UpdateBoringSpace(ctx *fiber.Ctx) error {
+	spaceID := ctx.Locals("spaceMember").(*models.BoringSpaceMember).BoringSpaceID
 
-	spaceIDStr := ctx.Params("id")
-	spaceID, err := strconv.ParseUint(spaceIDStr, 10, 32)
-	if err != nil {
+	input := &models.UpdateBoringSpaceRequest{}
+	if err := ctx.BodyParser(input); err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "fail",
-			"message": "Invalid BoringSpace ID",
+			"message": "Invalid input",
 		})
 	}
 
-	// Check if the user has permission to add members
-	space, err := h.service.GetBoringSpaceByID(context.Background(), uint(spaceID))
-	if err != nil {
-		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+	if err := validate.Struct(input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "fail",
-			"message": "BoringSpace not found",
+			"message": err.Error(),
 		})
 	}
 
-	isAdmin := false
-	for _, member := range space.Members {
-		if member.UserID == userID && member.Role == models.BSAdmin {
-			isAdmin = true
-			break
-		}
+	if input.Visibility != nil && !input.Visibility.IsValid() {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Visibility must be public, unlisted or private",
+		})
 	}
 
-	if !isAdmin {
-		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	space, err := h.service.UpdateBoringSpace(context.Background(), spaceID, input)
+	if err != nil {
+		return membershipError(ctx, err, "Could not update BoringSpace")
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status": "success",
+		"data":   space,
+	})
+}
+
+// DeleteBoringSpace can only be done by the owner, or a site admin who can see the space
+func (h *BoringSpaceHandler) DeleteBoringSpace(ctx *fiber.Ctx) error {
+	spaceID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "fail",
-			"message": "Permission denied",
+			"message": "Invalid BoringSpace ID",
 		})
 	}
 
+	space, err := h.service.GetBoringSpaceByID(context.Background(), uint(spaceID))
+	if err != nil {
+		return membershipError(ctx, err, "Could not delete BoringSpace")
+	}
+
+	if err := h.authz.OwnerOrAdmin(ctx, space.CreatorID); err != nil {
+		return policy.Deny(ctx, err)
+	}
+
+	if err := h.service.DeleteBoringSpace(context.Background(), space.ID); err != nil {
+		return membershipError(ctx, err, "Could not delete BoringSpace")
+	}
+
+	recordAudit(ctx, h.audit, models.AuditSpaceDeleted, 0, map[string]interface{}{"boringspace_id": space.ID, "name": space.Name})
+
+	return ctx.JSON(fiber.Map{
+		"status":  "success",
+		"message": "BoringSpace deleted",
+	})
+}
+
+// TransferOwnership hands the space to another member, who becomes an admin. Only the owner can do it.
+func (h *BoringSpaceHandler) TransferOwnership(ctx *fiber.Ctx) error {
+	member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
 	var input struct {
-		UserID uint                   `json:"user_id" validate:"required"`
-		Role   models.BoringSpaceRole `json:"role" validate:"required"`
+		UserID uint `json:"user_id" validate:"required"`
 	}
 
 	if err := ctx.BodyParser(&input); err != nil {
@@ -119,28 +202,47 @@ func (h *BoringSpaceHandler) AddMember(ctx *fiber.Ctx) error {
 		})
 	}
 
-	member := &models.BoringSpaceMember{
-		BoringSpaceID: uint(spaceID),
-		UserID:        input.UserID,
-		Role:          input.Role,
+	if err := validate.Struct(input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
 	}
 
-	if err := h.service.AddMember(context.Background(), member); err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Could not add member",
-		})
+	space, err := h.service.GetBoringSpaceByID(context.Background(), member.BoringSpaceID)
+	if err != nil {
+		return membershipError(ctx, err, "Could not transfer ownership")
+	}
+	if space.CreatorID != member.UserID {
+		return policy.Deny(ctx, policy.ErrForbidden)
 	}
 
-	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"status": "success",
-		"data":   member,
+	if err := h.service.TransferOwnership(context.Background(), space.ID, input.UserID); err != nil {
+		return membershipError(ctx, err, "Could not transfer ownership")
+	}
+
+	recordAudit(ctx, h.audit, models.AuditSpaceOwnerChanged, input.UserID, map[string]interface{}{"boringspace_id": space.ID, "previous_owner_id": member.UserID})
+
+	return ctx.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Ownership transferred",
 	})
 }
 
-func (h *BoringSpaceHandler) RemoveMember(ctx *fiber.Ctx) error {
-	userID := ctx.Locals("userId").(uint)
+func (h *BoringSpaceHandler) LeaveBoringSpace(ctx *fiber.Ctx) error {
+	member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
+	if err := h.service.LeaveBoringSpace(context.Background(), member.BoringSpaceID, member.UserID); err != nil {
+		return membershipError(ctx, err, "Could not leave BoringSpace")
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Left BoringSpace",
+	})
+}
 
+func (h *BoringSpaceHandler) RemoveMember(ctx *fiber.Ctx) error {
 	spaceIDStr := ctx.Params("id")
 	spaceID, err := strconv.ParseUint(spaceIDStr, 10, 32)
 	if err != nil {
@@ -159,35 +261,12 @@ func (h *BoringSpaceHandler) RemoveMember(ctx *fiber.Ctx) error {
 		})
 	}
 
-	// Check if the user has permission to remove members
-	space, err := h.service.GetBoringSpaceByID(context.Background(), uint(spaceID))
-	if err != nil {
-		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"status":  "fail",
-			"message": "BoringSpace not found",
-		})
-	}
-
-	isAdmin := false
-	for _, member := range space.Members {
-		if member.UserID == userID && member.Role == models.BSAdmin {
-			isAdmin = true
-			break
-		}
-	}
-
-	if !isAdmin {
-		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"status":  "fail",
-			"message": "Permission denied",
-		})
+	if ok, err := h.checkTargetRank(ctx, uint(spaceID), uint(targetUserID)); !ok {
+		return err
 	}
 
-	if err := h.service.RemoveMember(context.Background(), uint(spaceID), uint(targetUserID)); err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Could not remove member",
-		})
+	if err := h.service.RemoveMember(context.Background(), uint(spaceID), uint(targetUserID), ctx.Locals("userId").(uint)); err != nil {
+		return membershipError(ctx, err, "Could not remove member")
 	}
 
 	return ctx.JSON(fiber.Map{
@@ -221,8 +300,6 @@ func (h *BoringSpaceHandler) GetMembers(ctx *fiber.Ctx) error {
 }
 
 func (h *BoringSpaceHandler) UpdateMemberRole(ctx *fiber.Ctx) error {
-	userID := ctx.Locals("userId").(uint)
-
 	spaceIDStr := ctx.Params("id")
 	spaceID, err := strconv.ParseUint(spaceIDStr, 10, 32)
 	if err != nil {
@@ -241,64 +318,209 @@ func (h *BoringSpaceHandler) UpdateMemberRole(ctx *fiber.Ctx) error {
 		})
 	}
 
-	// Check if the user has permission to update roles
-	space, err := h.service.GetBoringSpaceByID(context.Background(), uint(spaceID))
-	if err != nil {
-		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+	var input struct {
+		Role models.BoringSpaceRole `json:"role" validate:"required"`
+	}
+
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "fail",
-			"message": "BoringSpace not found",
+			"message": "Invalid input",
 		})
 	}
 
-	isAdmin := false
-	for _, member := range space.Members {
-		if member.UserID == userID && member.Role == models.BSAdmin {
-			isAdmin = true
-			break
-		}
+	if ok, err := validateRole(ctx, h.service, uint(spaceID), input.Role); !ok {
+		return err
 	}
 
-	if !isAdmin {
-		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"status":  "fail",
-			"message": "Permission denied",
-		})
+	if ok, err := h.checkTargetRank(ctx, uint(spaceID), uint(targetUserID)); !ok {
+		return err
 	}
 
-	var input struct {
-		Role models.BoringSpaceRole `json:"role" validate:"required"`
+	if err := h.service.UpdateMemberRole(context.Background(), uint(spaceID), uint(targetUserID), input.Role); err != nil {
+		return membershipError(ctx, err, "Could not update member role")
 	}
 
-	if err := ctx.BodyParser(&input); err != nil {
+	recordAudit(ctx, h.audit, models.AuditRoleChanged, uint(targetUserID), map[string]interface{}{"boringspace_id": spaceID, "role": input.Role})
+
+	return ctx.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Member role updated",
+	})
+}
+
+func (h *BoringSpaceHandler) GetRoles(ctx *fiber.Ctx) error {
+	spaceID := ctx.Locals("spaceMember").(*models.BoringSpaceMember).BoringSpaceID
+
+	roles, err := h.service.GetRoles(context.Background(), spaceID)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve roles",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status": "success",
+		"data":   roles,
+	})
+}
+
+// SaveRole customizes a built-in role or creates a custom one, e.g. PUT /:id/roles/greeter {"permissions": ["post", "invite"]}
+func (h *BoringSpaceHandler) SaveRole(ctx *fiber.Ctx) error {
+	spaceID := ctx.Locals("spaceMember").(*models.BoringSpaceMember).BoringSpaceID
+	name := models.BoringSpaceRole(ctx.Params("role"))
+
+	input := &models.SaveSpaceRoleRequest{}
+	if err := ctx.BodyParser(input); err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "fail",
 			"message": "Invalid input",
 		})
 	}
 
-	if err := h.service.UpdateMemberRole(context.Background(), uint(spaceID), uint(targetUserID), input.Role); err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Could not update member role",
+	if err := validate.Struct(input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
 		})
 	}
 
+	role, err := h.service.SaveRole(context.Background(), spaceID, name, input.Permissions)
+	if err != nil {
+		return roleError(ctx, err, "Could not save role")
+	}
+
+	recordAudit(ctx, h.audit, models.AuditSpaceRoleSaved, 0, map[string]interface{}{"boringspace_id": spaceID, "role": name, "permissions": input.Permissions})
+
+	return ctx.JSON(fiber.Map{
+		"status": "success",
+		"data":   role,
+	})
+}
+
+// DeleteRole resets a built-in role to its defaults or deletes an unused custom role
+func (h *BoringSpaceHandler) DeleteRole(ctx *fiber.Ctx) error {
+	spaceID := ctx.Locals("spaceMember").(*models.BoringSpaceMember).BoringSpaceID
+	name := models.BoringSpaceRole(ctx.Params("role"))
+
+	if err := h.service.DeleteRole(context.Background(), spaceID, name); err != nil {
+		return roleError(ctx, err, "Could not delete role")
+	}
+
+	recordAudit(ctx, h.audit, models.AuditSpaceRoleDeleted, 0, map[string]interface{}{"boringspace_id": spaceID, "role": name})
+
 	return ctx.JSON(fiber.Map{
 		"status":  "success",
-		"message": "Member role updated",
+		"message": "Role deleted",
+	})
+}
+
+// validateRole rejects roles the space doesn't define and roles the caller is not senior enough to hand out.
+// When it returns false the response has been written and the handler should return err.
+func validateRole(ctx *fiber.Ctx, spaces models.BoringSpaceService, spaceID uint, role models.BoringSpaceRole) (bool, error) {
+	if err := spaces.ValidateRole(context.Background(), spaceID, role); err != nil {
+		return false, roleError(ctx, err, "Could not check role")
+	}
+
+	if !ctx.Locals("spaceMember").(*models.BoringSpaceMember).Outranks(role) {
+		return false, policy.Deny(ctx, policy.ErrForbidden)
+	}
+	return true, nil
+}
+
+// checkTargetRank stops members from acting on someone ranked at or above them, responding like validateRole
+func (h *BoringSpaceHandler) checkTargetRank(ctx *fiber.Ctx, spaceID uint, targetUserID uint) (bool, error) {
+	target, err := h.service.GetMember(context.Background(), spaceID, targetUserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "Member not found",
+			})
+		}
+		return false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve member",
+		})
+	}
+
+	if !ctx.Locals("spaceMember").(*models.BoringSpaceMember).Outranks(target.Role) {
+		return false, policy.Deny(ctx, policy.ErrForbidden)
+	}
+	return true, nil
+}
+
+func membershipError(ctx *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "BoringSpace not found",
+		})
+	case errors.Is(err, models.ErrNotSpaceMember):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case errors.Is(err, models.ErrLastSpaceAdmin), errors.Is(err, models.ErrSpaceOwner):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case errors.Is(err, models.ErrSpaceBanned):
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+func roleError(ctx *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, models.ErrUnknownSpaceRole),
+		errors.Is(err, models.ErrInvalidSpaceRoleName),
+		errors.Is(err, models.ErrInvalidSpacePermission),
+		errors.Is(err, models.ErrSpaceAdminRoleFixed):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case errors.Is(err, models.ErrSpaceRoleInUse):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": message,
 	})
 }
 
-func NewBoringSpaceHandler(route fiber.Router, service models.BoringSpaceService, userService models.UserService) {
+func NewBoringSpaceHandler(route fiber.Router, service models.BoringSpaceService, userService models.UserService, audit models.AuditService, authz *policy.Authorizer) {
 	handler := &BoringSpaceHandler{
 		service:     service,
 		userService: userService,
+		audit:       audit,
+		authz:       authz,
 	}
 
 	route.Post("/", handler.CreateBoringSpace)
-	route.Get("/:id", handler.GetBoringSpaceByID)
-	route.Post("/:id/members", handler.AddMember)
-	route.Delete("/:id/members/:userId", handler.RemoveMember)
-	route.Get("/:id/members", handler.GetMembers)
-	route.Put("/:id/members/:userId/role", handler.UpdateMemberRole)
+	route.Get("/", handler.GetBoringSpaces)
+	route.Get("/:id", authz.RequireSpaceVisible(), handler.GetBoringSpaceByID)
+	route.Put("/:id", authz.RequireSpaceRole(models.BSAdmin), handler.UpdateBoringSpace)
+	route.Delete("/:id", authz.RequireSpaceVisible(), handler.DeleteBoringSpace)
+	route.Post("/:id/transfer-ownership", authz.RequireSpaceRole(models.BSAdmin), handler.TransferOwnership)
+	route.Post("/:id/leave", authz.RequireSpaceRole(models.BSViewer), handler.LeaveBoringSpace)
+	route.Delete("/:id/members/:userId", authz.RequireSpacePermission(models.SpacePermKick), handler.RemoveMember)
+	route.Get("/:id/members", authz.RequireSpaceVisible(), handler.GetMembers)
+	route.Put("/:id/members/:userId/role", authz.RequireSpacePermission(models.SpacePermChangeRoles), handler.UpdateMemberRole)
+	route.Get("/:id/roles", authz.RequireSpaceRole(models.BSViewer), handler.GetRoles)
+	route.Put("/:id/roles/:role", authz.RequireSpaceRole(models.BSAdmin), handler.SaveRole)
+	route.Delete("/:id/roles/:role", authz.RequireSpaceRole(models.BSAdmin), handler.DeleteRole)
 }
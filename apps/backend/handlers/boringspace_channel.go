@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/policy"
+	"gorm.io/gorm"
+)
+
+type BoringSpaceChannelHandler struct {
+	service models.BoringSpaceChannelService
+}
+
+func (h *BoringSpaceChannelHandler) CreateChannel(ctx *fiber.Ctx) error {
+	member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
+	input := &models.CreateChannelRequest{}
+	if err := ctx.BodyParser(input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid input",
+		})
+	}
+
+	if err := validate.Struct(input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	channel, err := h.service.CreateChannel(context.Background(), member.BoringSpaceID, input)
+	if err != nil {
+		return channelError(ctx, err, "Could not create channel")
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data":   channel,
+	})
+}
+
+func (h *BoringSpaceChannelHandler) GetChannels(ctx *fiber.Ctx) error {
+	member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
+	channels, err := h.service.GetChannels(context.Background(), member, ctx.QueryBool("archived"))
+	if err != nil {
+		return channelError(ctx, err, "Could not get channels")
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status": "success",
+		"data":   channels,
+	})
+}
+
+func (h *BoringSpaceChannelHandler) ArchiveChannel(ctx *fiber.Ctx) error {
+	member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
+	channelID, err := strconv.ParseUint(ctx.Params("channelId"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid channel ID",
+		})
+	}
+
+	if err := h.service.ArchiveChannel(context.Background(), member.BoringSpaceID, uint(channelID)); err != nil {
+		return channelError(ctx, err, "Could not archive channel")
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Channel archived",
+	})
+}
+
+func (h *BoringSpaceChannelHandler) GetMessages(ctx *fiber.Ctx) error {
+	member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
+	channelID, err := strconv.ParseUint(ctx.Params("channelId"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid channel ID",
+		})
+	}
+
+	page, err := pageRequest(ctx)
+	if err != nil {
+		return invalidCursor(ctx)
+	}
+
+	messages, next, err := h.service.GetMessages(context.Background(), member, uint(channelID), page)
+	if err != nil {
+		return channelError(ctx, err, "Could not get messages")
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status":      "success",
+		"data":        messages,
+		"next_cursor": next,
+	})
+}
+
+func (h *BoringSpaceChannelHandler) SendMessage(ctx *fiber.Ctx) error {
+	member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
+	channelID, err := strconv.ParseUint(ctx.Params("channelId"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid channel ID",
+		})
+	}
+
+	var input struct {
+		Content string `json:"content" validate:"required"`
+	}
+
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid input",
+		})
+	}
+
+	if err := validate.Struct(input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	message, err := h.service.SendMessage(context.Background(), member, uint(channelID), input.Content)
+	if err != nil {
+		return channelError(ctx, err, "Could not send message")
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data":   message,
+	})
+}
+
+func channelError(ctx *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Channel not found",
+		})
+	case errors.Is(err, models.ErrUnknownSpaceRole):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Minimum role must be viewer, member, moderator or admin",
+		})
+	case errors.Is(err, models.ErrChannelArchived):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case errors.Is(err, models.ErrChannelReadOnly):
+		return policy.Deny(ctx, policy.ErrForbidden)
+	}
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+func NewBoringSpaceChannelHandler(route fiber.Router, service models.BoringSpaceChannelService, authz *policy.Authorizer) {
+	handler := &BoringSpaceChannelHandler{
+		service: service,
+	}
+
+	isMember := authz.RequireSpaceRole(models.BSViewer)
+	isAdmin := authz.RequireSpaceRole(models.BSAdmin)
+
+	route.Get("/:id/channels", isMember, handler.GetChannels)
+	route.Post("/:id/channels", isAdmin, handler.CreateChannel)
+	route.Post("/:id/channels/:channelId/archive", isAdmin, handler.ArchiveChannel)
+	route.Get("/:id/channels/:channelId/messages", isMember, handler.GetMessages)
+	route.Post("/:id/channels/:channelId/messages", isMember, handler.SendMessage)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/policy"
+)
+
+// maxCalendarRange keeps a single calendar request to about a year of events
+const maxCalendarRange = 366 * 24 * time.Hour
+
+// BoringSpaceEventHandler serves a space's calendar. The events are regular events,
+// so they are edited and deleted through /event/:eventId.
+type BoringSpaceEventHandler struct {
+	repository models.EventRepository
+}
+
+func (h *BoringSpaceEventHandler) CreateEvent(ctx *fiber.Ctx) error {
+	member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
+	var input struct {
+		Name     string    `json:"name" validate:"required,max=200"`
+		Location string    `json:"location"`
+		Date     time.Time `json:"date" validate:"required"`
+	}
+
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid input",
+		})
+	}
+
+	if err := validate.Struct(input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	event := &models.Event{
+		Name:          input.Name,
+		Location:      input.Location,
+		Date:          input.Date,
+		CreatorID:     &member.UserID,
+		BoringSpaceID: &member.BoringSpaceID,
+	}
+
+	event, err := h.repository.CreateOne(context.Background(), event)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not create event",
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data":   event,
+	})
+}
+
+// GetCalendar lists the space's events between from and to, e.g. ?from=2024-06-01T00:00:00Z&to=2024-07-01T00:00:00Z.
+// Without them it shows the next 30 days.
+func (h *BoringSpaceEventHandler) GetCalendar(ctx *fiber.Ctx) error {
+	spaceID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid BoringSpace ID",
+		})
+	}
+
+	from := time.Now()
+	var to time.Time
+	for param, target := range map[string]*time.Time{"from": &from, "to": &to} {
+		if value := ctx.Query(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status":  "fail",
+					"message": "Invalid " + param + " time, expected RFC 3339",
+				})
+			}
+			*target = t
+		}
+	}
+	if to.IsZero() {
+		to = from.AddDate(0, 0, 30)
+	}
+
+	if !to.After(from) || to.Sub(from) > maxCalendarRange {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "to must be after from, and at most a year later",
+		})
+	}
+
+	events, err := h.repository.GetSpaceEvents(context.Background(), uint(spaceID), from, to)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not get events",
+		})
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status": "success",
+		"data":   events,
+	})
+}
+
+func NewBoringSpaceEventHandler(route fiber.Router, repository models.EventRepository, authz *policy.Authorizer) {
+	handler := &BoringSpaceEventHandler{
+		repository: repository,
+	}
+
+	route.Get("/:id/events", authz.RequireSpaceVisible(), handler.GetCalendar)
+	route.Post("/:id/events", authz.RequireSpacePermission(models.SpacePermManageEvents), handler.CreateEvent)
+}
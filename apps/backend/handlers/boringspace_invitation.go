@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/policy"
+	"gorm.io/gorm"
+)
+
+type BoringSpaceInvitationHandler struct {
+	service models.BoringSpaceInvitationService
+	spaces  models.BoringSpaceService
+}
+
+func (h *BoringSpaceInvitationHandler) Invite(ctx *fiber.Ctx) error {
+	member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
+	input := &models.CreateInvitationRequest{}
+	if err := ctx.BodyParser(input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid input",
+		})
+	}
+
+	if err := validate.Struct(input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	if input.Role != "" {
+		if ok, err := validateRole(ctx, h.spaces, member.BoringSpaceID, input.Role); !ok {
+			return err
+		}
+	}
+
+	invitation, err := h.service.Invite(context.Background(), member.BoringSpaceID, member.UserID, input)
+	if err != nil {
+		return invitationError(ctx, err, "Could not send invitation")
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data":   invitation,
+	})
+}
+
+func (h *BoringSpaceInvitationHandler) GetSpaceInvitations(ctx *fiber.Ctx) error {
+	spaceID := ctx.Locals("spaceMember").(*models.BoringSpaceMember).BoringSpaceID
+
+	invitations, err := h.service.GetSpaceInvitations(context.Background(), spaceID)
+	if err != nil {
+		return invitationError(ctx, err, "Could not retrieve invitations")
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status": "success",
+		"data":   invitations,
+	})
+}
+
+func (h *BoringSpaceInvitationHandler) RevokeInvitation(ctx *fiber.Ctx) error {
+	spaceID := ctx.Locals("spaceMember").(*models.BoringSpaceMember).BoringSpaceID
+
+	invitationID, err := strconv.ParseUint(ctx.Params("invitationId"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid invitation ID",
+		})
+	}
+
+	if err := h.service.RevokeInvitation(context.Background(), spaceID, uint(invitationID)); err != nil {
+		return invitationError(ctx, err, "Could not revoke invitation")
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitation revoked",
+	})
+}
+
+// GetMyInvitations lists the signed in user's pending invitations
+func (h *BoringSpaceInvitationHandler) GetMyInvitations(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	invitations, err := h.service.GetUserInvitations(context.Background(), userID)
+	if err != nil {
+		return invitationError(ctx, err, "Could not retrieve invitations")
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status": "success",
+		"data":   invitations,
+	})
+}
+
+func (h *BoringSpaceInvitationHandler) AcceptInvitation(ctx *fiber.Ctx) error {
+	return h.respond(ctx, true)
+}
+
+func (h *BoringSpaceInvitationHandler) DeclineInvitation(ctx *fiber.Ctx) error {
+	return h.respond(ctx, false)
+}
+
+func (h *BoringSpaceInvitationHandler) respond(ctx *fiber.Ctx, accept bool) error {
+	userID := ctx.Locals("userId").(uint)
+
+	invitationID, err := strconv.ParseUint(ctx.Params("invitationId"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid invitation ID",
+		})
+	}
+
+	invitation, err := h.service.RespondToInvitation(context.Background(), uint(invitationID), userID, accept)
+	if err != nil {
+		return invitationError(ctx, err, "Could not respond to invitation")
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status": "success",
+		"data":   invitation,
+	})
+}
+
+func (h *BoringSpaceInvitationHandler) CreateInviteCode(ctx *fiber.Ctx) error {
+	member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
+	input := &models.CreateInviteCodeRequest{}
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(input); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "Invalid input",
+			})
+		}
+	}
+
+	if err := validate.Struct(input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	if input.Role != "" {
+		if ok, err := validateRole(ctx, h.spaces, member.BoringSpaceID, input.Role); !ok {
+			return err
+		}
+	}
+
+	code, err := h.service.CreateInviteCode(context.Background(), member.BoringSpaceID, member.UserID, input)
+	if err != nil {
+		return invitationError(ctx, err, "Could not create invite code")
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data":   code,
+	})
+}
+
+func (h *BoringSpaceInvitationHandler) GetInviteCodes(ctx *fiber.Ctx) error {
+	spaceID := ctx.Locals("spaceMember").(*models.BoringSpaceMember).BoringSpaceID
+
+	codes, err := h.service.GetInviteCodes(context.Background(), spaceID)
+	if err != nil {
+		return invitationError(ctx, err, "Could not retrieve invite codes")
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status": "success",
+		"data":   codes,
+	})
+}
+
+func (h *BoringSpaceInvitationHandler) RevokeInviteCode(ctx *fiber.Ctx) error {
+	spaceID := ctx.Locals("spaceMember").(*models.BoringSpaceMember).BoringSpaceID
+
+	codeID, err := strconv.ParseUint(ctx.Params("codeId"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid invite code ID",
+		})
+	}
+
+	if err := h.service.RevokeInviteCode(context.Background(), spaceID, uint(codeID)); err != nil {
+		return invitationError(ctx, err, "Could not revoke invite code")
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invite code revoked",
+	})
+}
+
+func (h *BoringSpaceInvitationHandler) JoinWithCode(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	member, err := h.service.JoinWithCode(context.Background(), ctx.Params("code"), userID)
+	if err != nil {
+		return invitationError(ctx, err, "Could not join BoringSpace")
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data":   member,
+	})
+}
+
+func (h *BoringSpaceInvitationHandler) RequestToJoin(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	spaceID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid BoringSpace ID",
+		})
+	}
+
+	var input struct {
+		Message string `json:"message" validate:"max=500"`
+	}
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&input); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "Invalid input",
+			})
+		}
+	}
+
+	if err := validate.Struct(input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	request, err := h.service.RequestToJoin(context.Background(), uint(spaceID), userID, input.Message)
+	if err != nil {
+		return invitationError(ctx, err, "Could not request to join")
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data":   request,
+	})
+}
+
+func (h *BoringSpaceInvitationHandler) GetJoinRequests(ctx *fiber.Ctx) error {
+	spaceID := ctx.Locals("spaceMember").(*models.BoringSpaceMember).BoringSpaceID
+
+	requests, err := h.service.GetJoinRequests(context.Background(), spaceID)
+	if err != nil {
+		return invitationError(ctx, err, "Could not retrieve join requests")
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status": "success",
+		"data":   requests,
+	})
+}
+
+func (h *BoringSpaceInvitationHandler) ApproveJoinRequest(ctx *fiber.Ctx) error {
+	return h.review(ctx, true)
+}
+
+func (h *BoringSpaceInvitationHandler) RejectJoinRequest(ctx *fiber.Ctx) error {
+	return h.review(ctx, false)
+}
+
+func (h *BoringSpaceInvitationHandler) review(ctx *fiber.Ctx, approve bool) error {
+	member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
+	requestID, err := strconv.ParseUint(ctx.Params("requestId"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid join request ID",
+		})
+	}
+
+	request, err := h.service.ReviewJoinRequest(context.Background(), member.BoringSpaceID, uint(requestID), member.UserID, approve)
+	if err != nil {
+		return invitationError(ctx, err, "Could not review join request")
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status": "success",
+		"data":   request,
+	})
+}
+
+func invitationError(ctx *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Not found",
+		})
+	case errors.Is(err, models.ErrInvalidInviteCode):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case errors.Is(err, models.ErrAlreadySpaceMember),
+		errors.Is(err, models.ErrAlreadyInvited),
+		errors.Is(err, models.ErrInvitationClosed),
+		errors.Is(err, models.ErrJoinRequestPending),
+		errors.Is(err, models.ErrJoinRequestReviewed):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case errors.Is(err, models.ErrSpaceBanned):
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+func NewBoringSpaceInvitationHandler(route fiber.Router, service models.BoringSpaceInvitationService, spaces models.BoringSpaceService, authz *policy.Authorizer) {
+	handler := &BoringSpaceInvitationHandler{
+		service: service,
+		spaces:  spaces,
+	}
+
+	canInvite := authz.RequireSpacePermission(models.SpacePermInvite)
+
+	route.Get("/invitations/received", handler.GetMyInvitations)
+	route.Post("/invitations/:invitationId/accept", handler.AcceptInvitation)
+	route.Post("/invitations/:invitationId/decline", handler.DeclineInvitation)
+	route.Post("/join/:code", handler.JoinWithCode)
+
+	route.Post("/:id/invitations", canInvite, handler.Invite)
+	route.Get("/:id/invitations", canInvite, handler.GetSpaceInvitations)
+	route.Delete("/:id/invitations/:invitationId", canInvite, handler.RevokeInvitation)
+	route.Post("/:id/invite-codes", canInvite, handler.CreateInviteCode)
+	route.Get("/:id/invite-codes", canInvite, handler.GetInviteCodes)
+	route.Delete("/:id/invite-codes/:codeId", canInvite, handler.RevokeInviteCode)
+	route.Post("/:id/join-requests", handler.RequestToJoin)
+	route.Get("/:id/join-requests", canInvite, handler.GetJoinRequests)
+	route.Post("/:id/join-requests/:requestId/approve", canInvite, handler.ApproveJoinRequest)
+	route.Post("/:id/join-requests/:requestId/reject", canInvite, handler.RejectJoinRequest)
+}
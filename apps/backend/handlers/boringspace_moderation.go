@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/policy"
+	"gorm.io/gorm"
+)
+
+type BoringSpaceModerationHandler struct {
+	service models.BoringSpaceModerationService
+	spaces  models.BoringSpaceService
+}
+
+// Sanction returns the handler that bans, mutes or times out the :userId member
+func (h *BoringSpaceModerationHandler) Sanction(sanctionType models.SpaceSanctionType) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
+		targetUserID, err := strconv.ParseUint(ctx.Params("userId"), 10, 32)
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "Invalid User ID",
+			})
+		}
+
+		input := &models.SanctionRequest{}
+		if len(ctx.Body()) > 0 {
+			if err := ctx.BodyParser(input); err != nil {
+				return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status":  "fail",
+					"message": "Invalid input",
+				})
+			}
+		}
+
+		if err := validate.Struct(input); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "fail",
+				"message": err.Error(),
+			})
+		}
+
+		if ok, err := h.checkTarget(ctx, member, uint(targetUserID)); !ok {
+			return err
+		}
+
+		sanction, err := h.service.Sanction(context.Background(), member.BoringSpaceID, uint(targetUserID), member.UserID, sanctionType, input)
+		if err != nil {
+			return moderationError(ctx, err, "Could not "+string(sanctionType)+" member")
+		}
+
+		return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"status": "success",
+			"data":   sanction,
+		})
+	}
+}
+
+// Lift returns the handler that ends the :userId user's ban, mute or timeout early
+func (h *BoringSpaceModerationHandler) Lift(sanctionType models.SpaceSanctionType) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
+		targetUserID, err := strconv.ParseUint(ctx.Params("userId"), 10, 32)
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "Invalid User ID",
+			})
+		}
+
+		if ok, err := h.checkTarget(ctx, member, uint(targetUserID)); !ok {
+			return err
+		}
+
+		if err := h.service.Lift(context.Background(), member.BoringSpaceID, uint(targetUserID), member.UserID, sanctionType); err != nil {
+			return moderationError(ctx, err, "Could not lift "+string(sanctionType))
+		}
+
+		return ctx.JSON(fiber.Map{
+			"status":  "success",
+			"message": "Lifted " + string(sanctionType),
+		})
+	}
+}
+
+func (h *BoringSpaceModerationHandler) GetSanctions(ctx *fiber.Ctx) error {
+	member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
+	sanctions, err := h.service.GetSanctions(context.Background(), member.BoringSpaceID)
+	if err != nil {
+		return moderationError(ctx, err, "Could not get sanctions")
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status": "success",
+		"data":   sanctions,
+	})
+}
+
+func (h *BoringSpaceModerationHandler) GetLog(ctx *fiber.Ctx) error {
+	member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
+	limit, _ := strconv.Atoi(ctx.Query("limit", "20"))
+	offset, _ := strconv.Atoi(ctx.Query("offset", "0"))
+
+	entries, err := h.service.GetLog(context.Background(), member.BoringSpaceID, limit, offset)
+	if err != nil {
+		return moderationError(ctx, err, "Could not get moderation log")
+	}
+
+	return ctx.JSON(fiber.Map{
+		"status": "success",
+		"data":   entries,
+	})
+}
+
+// checkTarget stops moderators from acting on members ranked at or above them. Users who aren't
+// members have no rank, which lets moderators ban them before they join.
+// When it returns false the response has been written and the handler should return err.
+func (h *BoringSpaceModerationHandler) checkTarget(ctx *fiber.Ctx, member *models.BoringSpaceMember, targetUserID uint) (bool, error) {
+	target, err := h.spaces.GetMember(context.Background(), member.BoringSpaceID, targetUserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return true, nil
+		}
+		return false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve member",
+		})
+	}
+
+	if !member.Outranks(target.Role) {
+		return false, policy.Deny(ctx, policy.ErrForbidden)
+	}
+	return true, nil
+}
+
+func moderationError(ctx *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "BoringSpace not found",
+		})
+	case errors.Is(err, models.ErrSpaceSanctionMissing), errors.Is(err, models.ErrNotSpaceMember):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case errors.Is(err, models.ErrTimeoutDuration), errors.Is(err, models.ErrCannotModerateSelf):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case errors.Is(err, models.ErrSpaceOwner):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+func NewBoringSpaceModerationHandler(route fiber.Router, service models.BoringSpaceModerationService, spaces models.BoringSpaceService, authz *policy.Authorizer) {
+	handler := &BoringSpaceModerationHandler{
+		service: service,
+		spaces:  spaces,
+	}
+
+	canModerate := authz.RequireSpacePermission(models.SpacePermModerate)
+
+	route.Post("/:id/bans/:userId", canModerate, handler.Sanction(models.SpaceBan))
+	route.Delete("/:id/bans/:userId", canModerate, handler.Lift(models.SpaceBan))
+	route.Post("/:id/mutes/:userId", canModerate, handler.Sanction(models.SpaceMute))
+	route.Delete("/:id/mutes/:userId", canModerate, handler.Lift(models.SpaceMute))
+	route.Post("/:id/timeouts/:userId", canModerate, handler.Sanction(models.SpaceTimeout))
+	route.Delete("/:id/timeouts/:userId", canModerate, handler.Lift(models.SpaceTimeout))
+	route.Get("/:id/sanctions", canModerate, handler.GetSanctions)
+	route.Get("/:id/moderation-log", canModerate, handler.GetLog)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/policy"
+)
+
+// BoringSpacePostHandler serves the feed of a single space. The posts are public messages,
+// so likes, comments and deletes go through /public-messages/:id like any other post.
+type BoringSpacePostHandler struct {
+	service models.PublicMessageService
+}
+
+func (h *BoringSpacePostHandler) CreatePost(ctx *fiber.Ctx) error {
+	member := ctx.Locals("spaceMember").(*models.BoringSpaceMember)
+
+	var input struct {
+		Content  string `json:"content" validate:"required"`
+		MediaURL string `json:"media_url"`
+	}
+
+	if err := ctx.BodyParser(&input); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "fail", "message": "Invalid input"})
+	}
+
+	if input.Content == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "fail", "message": "Content is required"})
+	}
+
+	message := &models.PublicMessage{
+		UserID:        member.UserID,
+		BoringSpaceID: &member.BoringSpaceID,
+		Content:       input.Content,
+		MediaURL:      input.MediaURL,
+	}
+
+	if err := h.service.CreatePublicMessage(context.Background(), message); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to create message"})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{"status": "success", "data": message})
+}
+
+func (h *BoringSpacePostHandler) GetFeed(ctx *fiber.Ctx) error {
+	spaceID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "fail", "message": "Invalid BoringSpace ID"})
+	}
+
+	page, err := pageRequest(ctx)
+	if err != nil {
+		return invalidCursor(ctx)
+	}
+
+	messages, next, err := h.service.GetSpaceMessages(context.Background(), uint(spaceID), page)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to retrieve messages"})
+	}
+
+	return ctx.JSON(fiber.Map{"status": "success", "data": messages, "next_cursor": next})
+}
+
+func NewBoringSpacePostHandler(route fiber.Router, service models.PublicMessageService, authz *policy.Authorizer) {
+	handler := &BoringSpacePostHandler{
+		service: service,
+	}
+
+	route.Get("/:id/posts", authz.RequireSpaceVisible(), handler.GetFeed)
+	route.Post("/:id/posts", authz.RequireSpacePermission(models.SpacePermPost), handler.CreatePost)
+}
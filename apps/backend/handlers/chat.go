@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/montekkundan/bored/backend/models"
+	"gorm.io/gorm"
 )
 
 type ChatHandler struct {
@@ -18,6 +20,12 @@ func (h *ChatHandler) CreateChat(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"status": "fail", "message": err.Error()})
 	}
 
+	// Channels are only created through their BoringSpace
+	chat.BoringSpaceID = nil
+	chat.MinRole = ""
+	chat.Announcement = false
+	chat.ArchivedAt = nil
+
 	if err := h.repository.CreateChat(context.Background(), chat); err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"status": "fail", "message": err.Error()})
 	}
@@ -31,6 +39,10 @@ func (h *ChatHandler) AddMember(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"status": "fail", "message": err.Error()})
 	}
 
+	if ok, err := h.rejectChannel(ctx, member.ChatID); !ok {
+		return err
+	}
+
 	if err := h.repository.AddMember(context.Background(), member.ChatID, member.UserID); err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{"status": "fail", "message": err.Error()})
 	}
@@ -44,6 +56,10 @@ func (h *ChatHandler) SendMessage(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"status": "fail", "message": err.Error()})
 	}
 
+	if ok, err := h.rejectChannel(ctx, message.ChatID); !ok {
+		return err
+	}
+
 	if err := h.repository.SendMessage(context.Background(), message); err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{"status": "fail", "message": err.Error()})
 	}
@@ -57,12 +73,38 @@ func (h *ChatHandler) GetMessages(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"status": "fail", "message": "Invalid chat ID"})
 	}
 
-	messages, err := h.repository.GetMessages(context.Background(), uint(chatID))
+	if ok, err := h.rejectChannel(ctx, uint(chatID)); !ok {
+		return err
+	}
+
+	page, err := pageRequest(ctx)
+	if err != nil {
+		return invalidCursor(ctx)
+	}
+
+	messages, next, err := h.repository.GetMessages(context.Background(), uint(chatID), page)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{"status": "fail", "message": err.Error()})
 	}
 
-	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{"status": "success", "data": messages})
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{"status": "success", "data": messages, "next_cursor": next})
+}
+
+// rejectChannel keeps BoringSpace channels out of these routes, their access follows the space instead.
+// When it returns false the response has been written and the handler should return err.
+func (h *ChatHandler) rejectChannel(ctx *fiber.Ctx, chatID uint) (bool, error) {
+	chat, err := h.repository.GetChat(context.Background(), chatID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return true, nil
+		}
+		return false, ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{"status": "error", "message": "Could not retrieve chat"})
+	}
+
+	if chat.BoringSpaceID != nil {
+		return false, ctx.Status(fiber.StatusNotFound).JSON(&fiber.Map{"status": "fail", "message": "Chat not found"})
+	}
+	return true, nil
 }
 
 func NewChatHandler(route fiber.Router, repository models.ChatRepository) {
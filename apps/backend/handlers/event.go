@@ -3,14 +3,17 @@ package handlers
 import (
 	"context"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/policy"
 )
 
 type EventHandler struct {
 	repository models.EventRepository
+	authz      *policy.Authorizer
 }
 
 func (h *EventHandler) GetMany(ctx *fiber.Ctx) error {
@@ -47,6 +50,10 @@ func (h *EventHandler) GetOne(ctx *fiber.Ctx) error {
 		})
 	}
 
+	if ok, err := h.checkSpaceEvent(ctx, event, ""); !ok {
+		return err
+	}
+
 	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
 		"status":  "success",
 		"message": "",
@@ -68,6 +75,12 @@ func (h *EventHandler) CreateOne(ctx *fiber.Ctx) error {
 		})
 	}
 
+	// Space events are created through their space, which checks the creator may do so
+	event.BoringSpaceID = nil
+	if userID, ok := ctx.Locals("userId").(uint); ok {
+		event.CreatorID = &userID
+	}
+
 	event, err := h.repository.CreateOne(context, event)
 
 	if err != nil {
@@ -100,6 +113,27 @@ func (h *EventHandler) UpdateOne(ctx *fiber.Ctx) error {
 		})
 	}
 
+	// The owner and space of an event are fixed once it is created
+	for key := range updateData {
+		switch strings.ToLower(strings.ReplaceAll(key, "_", "")) {
+		case "id", "creatorid", "boringspaceid":
+			delete(updateData, key)
+		}
+	}
+
+	existing, err := h.repository.GetOne(context, uint(eventId))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+
+	if ok, err := h.checkSpaceEvent(ctx, existing, models.SpacePermManageEvents); !ok {
+		return err
+	}
+
 	event, err := h.repository.UpdateOne(context, uint(eventId), updateData)
 
 	if err != nil {
@@ -123,7 +157,19 @@ func (h *EventHandler) DeleteOne(ctx *fiber.Ctx) error {
 	context, cancel := context.WithTimeout(context.Background(), time.Duration(5*time.Second))
 	defer cancel()
 
-	err := h.repository.DeleteOne(context, uint(eventId))
+	event, err := h.repository.GetOne(context, uint(eventId))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	if ok, err := h.checkSpaceEvent(ctx, event, models.SpacePermManageEvents); !ok {
+		return err
+	}
+
+	err = h.repository.DeleteOne(context, uint(eventId))
 
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
@@ -135,9 +181,37 @@ func (h *EventHandler) DeleteOne(ctx *fiber.Ctx) error {
 	return ctx.SendStatus(fiber.StatusNoContent)
 }
 
-func NewEventHandler(router fiber.Router, repository models.EventRepository) {
+// checkSpaceEvent applies the rules of the event's space, if it has one. Events of a space the caller
+// can't see don't exist for them, and perm, when set, must be granted by their role in the space.
+// When it returns false the response has been written and the handler should return err.
+func (h *EventHandler) checkSpaceEvent(ctx *fiber.Ctx, event *models.Event, perm models.BoringSpacePermission) (bool, error) {
+	if event.BoringSpaceID == nil {
+		return true, nil
+	}
+
+	visible, err := h.authz.SpaceVisible(ctx, *event.BoringSpaceID)
+	if err != nil {
+		return false, policy.Deny(ctx, err)
+	}
+	if !visible {
+		return false, ctx.Status(fiber.StatusNotFound).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "Event not found",
+		})
+	}
+
+	if perm != "" {
+		if err := h.authz.SpaceCan(ctx, *event.BoringSpaceID, perm); err != nil {
+			return false, policy.Deny(ctx, err)
+		}
+	}
+	return true, nil
+}
+
+func NewEventHandler(router fiber.Router, repository models.EventRepository, authz *policy.Authorizer) {
 	handler := &EventHandler{
 		repository: repository,
+		authz:      authz,
 	}
 	router.Get("/", handler.GetMany)
 	router.Post("/", handler.CreateOne)
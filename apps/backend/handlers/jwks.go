@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/signing"
+)
+
+type JWKSHandler struct {
+	keys *signing.KeyManager
+}
+
+// GetJWKS serves the raw key set, without the usual envelope, as JWKS consumers expect
+func (h *JWKSHandler) GetJWKS(ctx *fiber.Ctx) error {
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return ctx.JSON(h.keys.JWKS())
+}
+
+func NewJWKSHandler(app *fiber.App, keys *signing.KeyManager) {
+	handler := &JWKSHandler{keys: keys}
+
+	app.Get("/.well-known/jwks.json", handler.GetJWKS)
+}
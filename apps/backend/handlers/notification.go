@@ -13,13 +13,18 @@ type NotificationHandler struct {
 
 func (h *NotificationHandler) GetNotifications(ctx *fiber.Ctx) error {
 	userID := ctx.Locals("userId").(uint)
-	notifications, err := h.service.GetNotifications(context.Background(), userID)
+	page, err := pageRequest(ctx)
+	if err != nil {
+		return invalidCursor(ctx)
+	}
+
+	notifications, next, err := h.service.GetNotifications(context.Background(), userID, page)
 
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"status": "fail", "message": err.Error()})
 	}
 
-	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{"status": "success", "data": notifications})
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{"status": "success", "data": notifications, "next_cursor": next})
 }
 
 func NewNotificationHandler(route fiber.Router, service models.NotificationService) {
@@ -2,29 +2,191 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/config"
+	"github.com/montekkundan/bored/backend/middlewares"
 	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/utils"
+	"gorm.io/gorm"
 )
 
+const oauthStateCookie = "oauth_state"
+
 type OAuthProviderHandler struct {
-	repository models.OAuthProviderRepository
+	service models.OAuthService
+	audit   models.AuditService
+	config  *config.EnvConfig
+}
+
+func (h *OAuthProviderHandler) GetProviders(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{"status": "success", "data": h.service.Providers()})
+}
+
+func (h *OAuthProviderHandler) Login(ctx *fiber.Ctx) error {
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(10*time.Second))
+	defer cancel()
+
+	authURL, state, err := h.service.AuthorizationURL(context, ctx.Params("provider"), 0)
+	if err != nil {
+		return h.fail(ctx, err)
+	}
+
+	h.setStateCookie(ctx, utils.HashToken(state), time.Now().Add(models.OAuthStateTTL))
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status": "success",
+		"data": &fiber.Map{
+			"authorization_url": authURL,
+		},
+	})
+}
+
+func (h *OAuthProviderHandler) Link(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(10*time.Second))
+	defer cancel()
+
+	authURL, state, err := h.service.AuthorizationURL(context, ctx.Params("provider"), userID)
+	if err != nil {
+		return h.fail(ctx, err)
+	}
+
+	h.setStateCookie(ctx, utils.HashToken(state), time.Now().Add(models.OAuthStateTTL))
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status": "success",
+		"data": &fiber.Map{
+			"authorization_url": authURL,
+		},
+	})
 }
 
-func (h *OAuthProviderHandler) AddProvider(ctx *fiber.Ctx) error {
-	provider := &models.OAuthProvider{}
-	if err := ctx.BodyParser(provider); err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"status": "fail", "message": err.Error()})
+func (h *OAuthProviderHandler) Callback(ctx *fiber.Ctx) error {
+	if providerErr := ctx.Query("error"); providerErr != "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"status": "fail", "message": providerErr})
+	}
+
+	code := ctx.Query("code")
+	state := ctx.Query("state")
+	if code == "" || state == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"status": "fail", "message": "Missing code or state"})
 	}
 
-	if err := h.repository.AddProvider(context.Background(), provider); err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"status": "fail", "message": err.Error()})
+	context, cancel := context.WithTimeout(context.Background(), time.Duration(10*time.Second))
+	defer cancel()
+
+	// The state is single use either way, so the cookie goes too
+	stateBinding := ctx.Cookies(oauthStateCookie)
+	h.setStateCookie(ctx, "", time.Unix(0, 0))
+
+	token, user, err := h.service.HandleCallback(context, ctx.Params("provider"), code, state, stateBinding, deviceInfo(ctx))
+
+	var lockout *models.LockoutError
+	if errors.As(err, &lockout) {
+		return lockedOut(ctx, lockout)
 	}
 
-	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{"status": "success", "message": "Provider added successfully"})
+	// Finished through POST /auth/2fa/challenge
+	var challenge *models.TwoFactorChallengeError
+	if errors.As(err, &challenge) {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": challenge.Error(),
+			"data": &fiber.Map{
+				"two_factor_required": true,
+				"challenge":           challenge.Challenge,
+			},
+		})
+	}
+
+	if err != nil {
+		return h.fail(ctx, err)
+	}
+
+	token, err = sessionResponse(ctx, h.config, token)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{"status": "error", "message": "Failed to start session"})
+	}
+
+	recordAudit(ctx, h.audit, models.AuditOAuthLogin, user.ID, map[string]interface{}{"provider": ctx.Params("provider")})
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "Successfully logged in",
+		"data": &fiber.Map{
+			"token": token,
+			"user":  user,
+		},
+	})
+}
+
+func (h *OAuthProviderHandler) GetLinkedProviders(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	providers, err := h.service.GetLinkedProviders(context.Background(), userID)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{"status": "fail", "message": err.Error()})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{"status": "success", "data": providers})
+}
+
+func (h *OAuthProviderHandler) UnlinkProvider(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	id, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"status": "fail", "message": "Invalid provider ID"})
+	}
+
+	if err := h.service.UnlinkProvider(context.Background(), userID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(&fiber.Map{"status": "fail", "message": "Provider not found"})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{"status": "fail", "message": err.Error()})
+	}
+
+	recordAudit(ctx, h.audit, models.AuditOAuthUnlinked, userID, map[string]interface{}{"oauth_provider_id": id})
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{"status": "success", "message": "Provider unlinked"})
+}
+
+// setStateCookie ties a login or link flow to the browser that started it. Lax, unlike the session
+// cookies, so it survives the top-level redirect back from the provider.
+func (h *OAuthProviderHandler) setStateCookie(ctx *fiber.Ctx, value string, expires time.Time) {
+	ctx.Cookie(&fiber.Cookie{
+		Name:     oauthStateCookie,
+		Value:    value,
+		Path:     "/api/auth/oauth",
+		Domain:   h.config.CookieDomain,
+		Expires:  expires,
+		Secure:   h.config.CookieSecure,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+func (h *OAuthProviderHandler) fail(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, models.ErrUnknownOAuthProvider) {
+		return ctx.Status(fiber.StatusNotFound).JSON(&fiber.Map{"status": "fail", "message": err.Error()})
+	}
+	if errors.Is(err, models.ErrUnverifiedAccountExists) {
+		return ctx.Status(fiber.StatusConflict).JSON(&fiber.Map{"status": "fail", "message": err.Error()})
+	}
+	return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{"status": "fail", "message": err.Error()})
 }
 
-func NewOAuthProviderHandler(route fiber.Router, repository models.OAuthProviderRepository) {
-	handler := &OAuthProviderHandler{repository: repository}
-	route.Post("/oauth/provider", handler.AddProvider)
+func NewOAuthProviderHandler(route fiber.Router, service models.OAuthService, audit models.AuditService, config *config.EnvConfig, authProtected fiber.Handler) {
+	handler := &OAuthProviderHandler{service: service, audit: audit, config: config}
+	route.Get("/providers", handler.GetProviders)
+	route.Get("/linked", authProtected, handler.GetLinkedProviders)
+	route.Delete("/linked/:id", authProtected, middlewares.RejectImpersonation(), handler.UnlinkProvider)
+	route.Get("/:provider/login", handler.Login)
+	route.Post("/:provider/link", authProtected, middlewares.RejectImpersonation(), handler.Link)
+	route.Get("/:provider/callback", handler.Callback)
 }
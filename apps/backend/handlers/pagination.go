@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/models"
+)
+
+// pageRequest reads the ?cursor= and ?limit= params every paginated list takes. The cursor is
+// the next_cursor of the previous page, and is empty on the last one.
+func pageRequest(ctx *fiber.Ctx) (*models.PageRequest, error) {
+	return models.NewPageRequest(ctx.Query("cursor"), ctx.QueryInt("limit"))
+}
+
+func invalidCursor(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "fail", "message": "Invalid cursor"})
+}
@@ -2,15 +2,19 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/policy"
+	"gorm.io/gorm"
 )
 
 type PublicMessageHandler struct {
 	service     models.PublicMessageService
 	userService models.UserService
+	authz       *policy.Authorizer
 }
 
 func (h *PublicMessageHandler) CreatePublicMessage(ctx *fiber.Ctx) error {
@@ -44,15 +48,17 @@ func (h *PublicMessageHandler) CreatePublicMessage(ctx *fiber.Ctx) error {
 
 // GetPublicMessages handles GET /public-messages
 func (h *PublicMessageHandler) GetPublicMessages(ctx *fiber.Ctx) error {
-	limit, _ := strconv.Atoi(ctx.Query("limit", "20"))
-	offset, _ := strconv.Atoi(ctx.Query("offset", "0"))
+	page, err := pageRequest(ctx)
+	if err != nil {
+		return invalidCursor(ctx)
+	}
 
-	messages, err := h.service.GetPublicMessages(context.Background(), limit, offset)
+	messages, next, err := h.service.GetPublicMessages(context.Background(), page)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to retrieve messages"})
 	}
 
-	return ctx.JSON(fiber.Map{"status": "success", "data": messages})
+	return ctx.JSON(fiber.Map{"status": "success", "data": messages, "next_cursor": next})
 }
 
 // GetPublicMessageByID handles GET /public-messages/:id
@@ -63,9 +69,9 @@ func (h *PublicMessageHandler) GetPublicMessageByID(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "fail", "message": "Invalid message ID"})
 	}
 
-	message, err := h.service.GetPublicMessageByID(context.Background(), uint(messageID))
+	message, err := h.loadMessage(ctx, uint(messageID), "")
 	if err != nil {
-		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "fail", "message": "Message not found"})
+		return messageError(ctx, err)
 	}
 
 	return ctx.JSON(fiber.Map{"status": "success", "data": message})
@@ -73,24 +79,24 @@ func (h *PublicMessageHandler) GetPublicMessageByID(ctx *fiber.Ctx) error {
 
 // DeletePublicMessage handles DELETE /public-messages/:id
 func (h *PublicMessageHandler) DeletePublicMessage(ctx *fiber.Ctx) error {
-	userID := ctx.Locals("userId").(uint)
 	messageIDStr := ctx.Params("id")
 	messageID, err := strconv.ParseUint(messageIDStr, 10, 32)
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "fail", "message": "Invalid message ID"})
 	}
 
-	message, err := h.service.GetPublicMessageByID(context.Background(), uint(messageID))
+	message, err := h.loadMessage(ctx, uint(messageID), "")
 	if err != nil {
-		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "fail", "message": "Message not found"})
+		return messageError(ctx, err)
 	}
 
-	// Check if the user is the author or an admin
-	if message.UserID != userID {
-		requestingUser, err := h.userService.GetUserByID(context.Background(), userID)
-		if err != nil || !requestingUser.HasRole(models.Admin) {
-			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"status": "fail", "message": "Permission denied"})
-		}
+	err = h.authz.OwnerOrAdmin(ctx, message.UserID)
+	// Space moderators can take down posts in their space too
+	if errors.Is(err, policy.ErrForbidden) && message.BoringSpaceID != nil {
+		err = h.authz.SpaceCan(ctx, *message.BoringSpaceID, models.SpacePermModerate)
+	}
+	if err != nil {
+		return policy.Deny(ctx, err)
 	}
 
 	if err := h.service.DeletePublicMessage(context.Background(), uint(messageID)); err != nil {
@@ -109,6 +115,10 @@ func (h *PublicMessageHandler) LikePublicMessage(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "fail", "message": "Invalid message ID"})
 	}
 
+	if _, err := h.loadMessage(ctx, uint(messageID), models.SpacePermComment); err != nil {
+		return messageError(ctx, err)
+	}
+
 	if err := h.service.LikePublicMessage(context.Background(), uint(messageID), userID); err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to like message"})
 	}
@@ -125,6 +135,10 @@ func (h *PublicMessageHandler) UnlikePublicMessage(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "fail", "message": "Invalid message ID"})
 	}
 
+	if _, err := h.loadMessage(ctx, uint(messageID), models.SpacePermComment); err != nil {
+		return messageError(ctx, err)
+	}
+
 	if err := h.service.UnlikePublicMessage(context.Background(), uint(messageID), userID); err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to unlike message"})
 	}
@@ -149,6 +163,10 @@ func (h *PublicMessageHandler) CreateComment(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "fail", "message": "Content is required"})
 	}
 
+	if _, err := h.loadMessage(ctx, uint(messageID), models.SpacePermComment); err != nil {
+		return messageError(ctx, err)
+	}
+
 	comment := &models.Comment{
 		PublicMessageID: uint(messageID),
 		UserID:          userID,
@@ -170,18 +188,65 @@ func (h *PublicMessageHandler) GetComments(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "fail", "message": "Invalid message ID"})
 	}
 
-	comments, err := h.service.GetCommentsByMessageID(context.Background(), uint(messageID))
+	if _, err := h.loadMessage(ctx, uint(messageID), ""); err != nil {
+		return messageError(ctx, err)
+	}
+
+	page, err := pageRequest(ctx)
+	if err != nil {
+		return invalidCursor(ctx)
+	}
+
+	comments, next, err := h.service.GetCommentsByMessageID(context.Background(), uint(messageID), page)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to retrieve comments"})
 	}
 
-	return ctx.JSON(fiber.Map{"status": "success", "data": comments})
+	return ctx.JSON(fiber.Map{"status": "success", "data": comments, "next_cursor": next})
+}
+
+// loadMessage fetches a message, applying the rules of the space it was posted in. Posts in a space the
+// caller can't see don't exist for them, and perm, when set, must be granted by their role in the space.
+func (h *PublicMessageHandler) loadMessage(ctx *fiber.Ctx, messageID uint, perm models.BoringSpacePermission) (*models.PublicMessage, error) {
+	message, err := h.service.GetPublicMessageByID(context.Background(), messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.BoringSpaceID == nil {
+		return message, nil
+	}
+
+	visible, err := h.authz.SpaceVisible(ctx, *message.BoringSpaceID)
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	if perm != "" {
+		if err := h.authz.SpaceCan(ctx, *message.BoringSpaceID, perm); err != nil {
+			return nil, err
+		}
+	}
+	return message, nil
+}
+
+func messageError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "fail", "message": "Message not found"})
+	case errors.Is(err, policy.ErrForbidden):
+		return policy.Deny(ctx, err)
+	}
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to retrieve message"})
 }
 
-func NewPublicMessageHandler(route fiber.Router, service models.PublicMessageService, userService models.UserService) {
+func NewPublicMessageHandler(route fiber.Router, service models.PublicMessageService, userService models.UserService, authz *policy.Authorizer) {
 	handler := &PublicMessageHandler{
 		service:     service,
 		userService: userService,
+		authz:       authz,
 	}
 
 	route.Post("/", handler.CreatePublicMessage)
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/models"
+	"gorm.io/gorm"
+)
+
+type SessionHandler struct {
+	authService models.AuthService
+	audit       models.AuditService
+}
+
+func (h *SessionHandler) GetSessions(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+	sessionID, _ := ctx.Locals("sessionId").(string)
+
+	sessions, err := h.authService.ListSessions(ctx.Context(), userID, sessionID)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve sessions",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status": "success",
+		"data":   sessions,
+	})
+}
+
+func (h *SessionHandler) RevokeSession(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+
+	if err := h.authService.RevokeSession(ctx.Context(), userID, ctx.Params("id")); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "Session not found",
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+			"status":  "error",
+			"message": "Could not revoke session",
+		})
+	}
+
+	recordAudit(ctx, h.audit, models.AuditSessionRevoked, userID, map[string]interface{}{"session_id": ctx.Params("id")})
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "Session revoked",
+	})
+}
+
+func (h *SessionHandler) RevokeOtherSessions(ctx *fiber.Ctx) error {
+	userID := ctx.Locals("userId").(uint)
+	sessionID, _ := ctx.Locals("sessionId").(string)
+
+	if err := h.authService.RevokeOtherSessions(ctx.Context(), userID, sessionID); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+			"status":  "error",
+			"message": "Could not revoke sessions",
+		})
+	}
+
+	recordAudit(ctx, h.audit, models.AuditSessionRevoked, userID, map[string]interface{}{"kept_session_id": sessionID})
+
+	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"status":  "success",
+		"message": "All other sessions revoked",
+	})
+}
+
+func NewSessionHandler(route fiber.Router, authService models.AuthService, audit models.AuditService) {
+	handler := &SessionHandler{authService: authService, audit: audit}
+
+	route.Get("/", handler.GetSessions)
+	route.Delete("/others", handler.RevokeOtherSessions)
+	route.Delete("/:id", handler.RevokeSession)
+}
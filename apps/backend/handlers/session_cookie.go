@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/config"
+	"github.com/montekkundan/bored/backend/middlewares"
+	"github.com/montekkundan/bored/backend/utils"
+)
+
+// SessionModeHeader lets browser clients ask for the refresh token as an HttpOnly cookie
+// instead of in the response body, which stays the default for the mobile app
+const SessionModeHeader = "X-Session-Mode"
+
+func cookieMode(ctx *fiber.Ctx) bool {
+	return strings.EqualFold(ctx.Get(SessionModeHeader), "cookie")
+}
+
+// sessionResponse returns the token payload for the client's session mode. In cookie mode the
+// refresh token moves into an HttpOnly cookie and a fresh CSRF token is issued alongside it.
+func sessionResponse(ctx *fiber.Ctx, cfg *config.EnvConfig, tokens map[string]string) (map[string]string, error) {
+	if !cookieMode(ctx) {
+		return tokens, nil
+	}
+
+	csrfToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	expires := time.Now().Add(time.Hour * 24 * time.Duration(cfg.RefreshTokenExpiry))
+
+	ctx.Cookie(&fiber.Cookie{
+		Name:     middlewares.RefreshTokenCookie,
+		Value:    tokens["refresh_token"],
+		Path:     "/api/auth",
+		Domain:   cfg.CookieDomain,
+		Expires:  expires,
+		Secure:   cfg.CookieSecure,
+		HTTPOnly: true,
+		SameSite: cfg.CookieSameSite,
+	})
+	// Readable by the frontend so it can echo it back in the X-CSRF-Token header
+	ctx.Cookie(&fiber.Cookie{
+		Name:     middlewares.CSRFCookie,
+		Value:    csrfToken,
+		Path:     "/",
+		Domain:   cfg.CookieDomain,
+		Expires:  expires,
+		Secure:   cfg.CookieSecure,
+		SameSite: cfg.CookieSameSite,
+	})
+
+	return map[string]string{
+		"access_token": tokens["access_token"],
+		"csrf_token":   csrfToken,
+	}, nil
+}
+
+func clearSessionCookies(ctx *fiber.Ctx, cfg *config.EnvConfig) {
+	for name, path := range map[string]string{middlewares.RefreshTokenCookie: "/api/auth", middlewares.CSRFCookie: "/"} {
+		ctx.Cookie(&fiber.Cookie{
+			Name:     name,
+			Path:     path,
+			Domain:   cfg.CookieDomain,
+			Expires:  time.Unix(0, 0),
+			MaxAge:   -1,
+			Secure:   cfg.CookieSecure,
+			HTTPOnly: name == middlewares.RefreshTokenCookie,
+			SameSite: cfg.CookieSameSite,
+		})
+	}
+}
+
+// refreshTokenFrom prefers a token in the JSON body, as sent by the mobile app, over the session cookie
+func refreshTokenFrom(ctx *fiber.Ctx) string {
+	var payload struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := ctx.BodyParser(&payload); err == nil && payload.RefreshToken != "" {
+		return payload.RefreshToken
+	}
+	return ctx.Cookies(middlewares.RefreshTokenCookie)
+}
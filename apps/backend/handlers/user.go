@@ -5,53 +5,17 @@ import (
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/middlewares"
 	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/policy"
 )
 
 type UserHandler struct {
 	service models.UserService
+	audit   models.AuditService
 }
 
 func (h *UserHandler) GetAllUsers(ctx *fiber.Ctx) error {
-	userIdValue := ctx.Locals("userId")
-	if userIdValue == nil {
-		return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
-			"status":  "fail",
-			"message": "User ID not found in the context",
-		})
-	}
-	userID, ok := userIdValue.(uint)
-
-	if !ok {
-		return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
-			"status":  "fail",
-			"message": "Invalid user ID",
-		})
-	}
-
-	user, err := h.service.GetUserByID(context.Background(), userID)
-	if err != nil {
-		return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
-			"status":  "fail",
-			"message": "Unauthorized",
-		})
-	}
-
-	isAdmin := false
-	for _, role := range user.Roles {
-		if models.UserRole(role) == models.Admin {
-			isAdmin = true
-			break
-		}
-	}
-
-	if !isAdmin {
-		return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
-			"status":  "fail",
-			"message": "Unauthorized",
-		})
-	}
-
 	users, err := h.service.GetAllUsers(context.Background())
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
@@ -144,6 +108,8 @@ func (h *UserHandler) DeleteUser(ctx *fiber.Ctx) error {
 		})
 	}
 
+	recordAudit(ctx, h.audit, models.AuditUserDeleted, userID, nil)
+
 	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
 		"status":  "success",
 		"message": "User deleted successfully",
@@ -161,6 +127,8 @@ func (h *UserHandler) DeactivateAccount(ctx *fiber.Ctx) error {
 		})
 	}
 
+	recordAudit(ctx, h.audit, models.AuditUserDeactivated, userID, nil)
+
 	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
 		"status":  "success",
 		"message": "Account deactivated successfully",
@@ -168,17 +136,6 @@ func (h *UserHandler) DeactivateAccount(ctx *fiber.Ctx) error {
 }
 
 func (h *UserHandler) AdminDeleteUser(ctx *fiber.Ctx) error {
-	adminUser := ctx.Locals("userId").(uint)
-
-	// Check if the user has Admin privileges
-	user, err := h.service.GetUserByID(context.Background(), adminUser)
-	if err != nil || !user.HasRole(models.Admin) {
-		return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
-			"status":  "fail",
-			"message": "Admin privileges required",
-		})
-	}
-
 	targetUserIDStr := ctx.Params("id")
 	targetUserID, err := strconv.ParseUint(targetUserIDStr, 10, 32)
 	if err != nil {
@@ -196,6 +153,8 @@ func (h *UserHandler) AdminDeleteUser(ctx *fiber.Ctx) error {
 		})
 	}
 
+	recordAudit(ctx, h.audit, models.AuditUserDeleted, uint(targetUserID), nil)
+
 	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
 		"status":  "success",
 		"message": "User deleted successfully",
@@ -233,27 +192,30 @@ func (h *UserHandler) GetUserBoringSpaces(ctx *fiber.Ctx) error {
 }
 
 func (h *UserHandler) GetAllPublicMessages(ctx *fiber.Ctx) error {
-	limit, _ := strconv.Atoi(ctx.Query("limit", "20"))
-	offset, _ := strconv.Atoi(ctx.Query("offset", "0"))
+	page, err := pageRequest(ctx)
+	if err != nil {
+		return invalidCursor(ctx)
+	}
 
-	messages, err := h.service.GetAllPublicMessages(context.Background(), limit, offset)
+	messages, next, err := h.service.GetAllPublicMessages(context.Background(), page)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to retrieve messages"})
 	}
 
-	return ctx.JSON(fiber.Map{"status": "success", "data": messages})
+	return ctx.JSON(fiber.Map{"status": "success", "data": messages, "next_cursor": next})
 }
 
-func NewUserHandler(route fiber.Router, service models.UserService) {
+func NewUserHandler(route fiber.Router, service models.UserService, audit models.AuditService, authz *policy.Authorizer) {
 	handler := &UserHandler{
 		service: service,
+		audit:   audit,
 	}
 
-	route.Get("/get-all", handler.GetAllUsers)
+	route.Get("/get-all", authz.RequireRole(models.Admin), handler.GetAllUsers)
 	route.Put("/update-user", handler.UpdateUser)
-	route.Delete("/delete", handler.DeleteUser)
-	route.Put("/deactivate-account", handler.DeactivateAccount)
-	route.Delete("/admin-delete/:id", handler.AdminDeleteUser)
+	route.Delete("/delete", middlewares.RejectAPIKeys(), middlewares.RejectImpersonation(), handler.DeleteUser)
+	route.Put("/deactivate-account", middlewares.RejectAPIKeys(), middlewares.RejectImpersonation(), handler.DeactivateAccount)
+	route.Delete("/admin-delete/:id", middlewares.RejectAPIKeys(), middlewares.RejectImpersonation(), authz.RequireRole(models.Admin), handler.AdminDeleteUser)
 	route.Get("/boringspaces", handler.GetUserBoringSpaces)
 	route.Get("/public-messages", handler.GetAllPublicMessages)
 }
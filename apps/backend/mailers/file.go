@@ -0,0 +1,29 @@
+package mailers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/montekkundan/bored/backend/models"
+)
+
+// FileMailer writes every message to an .eml file so flows can be followed locally
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, mail *models.Mail) error {
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), filepath.Base(mail.To))
+	return os.WriteFile(filepath.Join(m.dir, name), buildMessage(m.from, mail), 0o644)
+}
@@ -0,0 +1,29 @@
+package mailers
+
+import (
+	"fmt"
+
+	"github.com/montekkundan/bored/backend/config"
+	"github.com/montekkundan/bored/backend/models"
+)
+
+// NewMailer picks the delivery backend configured through MAILER
+func NewMailer(config *config.EnvConfig) (models.Mailer, error) {
+	switch config.Mailer {
+	case "smtp":
+		return NewSMTPMailer(config.SMTPHost, config.SMTPPort, config.SMTPUsername, config.SMTPPassword, config.MailFrom), nil
+	case "file", "":
+		return NewFileMailer(config.MailDir, config.MailFrom)
+	case "memory":
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", config.Mailer)
+	}
+}
+
+func buildMessage(from string, mail *models.Mail) []byte {
+	return []byte(fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=\"utf-8\"\r\n\r\n%s\r\n",
+		from, mail.To, mail.Subject, mail.Body,
+	))
+}
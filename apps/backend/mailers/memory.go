@@ -0,0 +1,32 @@
+package mailers
+
+import (
+	"context"
+	"sync"
+
+	"github.com/montekkundan/bored/backend/models"
+)
+
+// MemoryMailer keeps sent messages in memory for tests
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []models.Mail
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, mail *models.Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, *mail)
+	return nil
+}
+
+// Sent returns a copy of every message delivered so far
+func (m *MemoryMailer) Sent() []models.Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.Mail(nil), m.sent...)
+}
@@ -0,0 +1,32 @@
+package mailers
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+
+	"github.com/montekkundan/bored/backend/models"
+)
+
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPMailer{
+		addr: fmt.Sprintf("%s:%s", host, port),
+		auth: auth,
+		from: from,
+	}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, mail *models.Mail) error {
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{mail.To}, buildMessage(m.from, mail))
+}
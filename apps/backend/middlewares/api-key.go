@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/models"
+)
+
+// APIKeyOrAuthProtected accepts either an API key or, falling through to authProtected, a user JWT.
+// Requests made with a key carry it in Locals("apiKey") so RequireScope can check it.
+func APIKeyOrAuthProtected(authProtected fiber.Handler, apiKeyService models.APIKeyService) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		rawKey, ok := strings.CutPrefix(ctx.Get("Authorization"), "Bearer "+models.APIKeyPrefix)
+		if !ok {
+			return authProtected(ctx)
+		}
+
+		key, err := apiKeyService.Authenticate(ctx.Context(), models.APIKeyPrefix+rawKey, ctx.IP())
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidAPIKey) {
+				return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+					"status":  "fail",
+					"message": err.Error(),
+				})
+			}
+			log.Printf("Unable to authenticate api key: %v", err)
+			return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "Internal server error",
+			})
+		}
+
+		ctx.Locals("userId", key.UserID)
+		ctx.Locals("apiKey", key)
+
+		return ctx.Next()
+	}
+}
+
+// RequireScope lets API keys through only with "<resource>:read" for safe methods or
+// "<resource>:write" otherwise. User sessions are not scoped.
+func RequireScope(resource string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		key, ok := ctx.Locals("apiKey").(*models.APIKey)
+		if !ok {
+			return ctx.Next()
+		}
+
+		scope := resource + ":write"
+		if ctx.Method() == fiber.MethodGet || ctx.Method() == fiber.MethodHead {
+			scope = resource + ":read"
+		}
+
+		if !key.HasScope(scope) {
+			return ctx.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": models.ErrInsufficientScope.Error(),
+				"data": &fiber.Map{
+					"required_scope": scope,
+				},
+			})
+		}
+
+		return ctx.Next()
+	}
+}
+
+// RejectAPIKeys keeps account management, such as sessions and the keys themselves, to real logins
+func RejectAPIKeys() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if _, ok := ctx.Locals("apiKey").(*models.APIKey); ok {
+			return ctx.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "This endpoint cannot be used with an api key",
+			})
+		}
+		return ctx.Next()
+	}
+}
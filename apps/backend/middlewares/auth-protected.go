@@ -2,19 +2,21 @@ package middlewares
 
 import (
 	"errors"
-	"fmt"
 	"log"
-	"os"
 	"strings"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/signing"
+	"github.com/montekkundan/bored/backend/utils"
 	"gorm.io/gorm"
 )
 
-func AuthProtected(db *gorm.DB) fiber.Handler {
+// AuthProtected accepts user access tokens, including an admin's impersonation token. Requests made while
+// impersonating carry the admin in Locals("impersonatorId") and are each written to the audit log.
+func AuthProtected(db *gorm.DB, redisClient *redis.Client, keys *signing.KeyManager, audit models.AuditService) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		log.Println("Middleware: AuthProtected invoked")
 		authHeader := ctx.Get("Authorization")
@@ -38,18 +40,9 @@ func AuthProtected(db *gorm.DB) fiber.Handler {
 		}
 
 		tokenStr := tokenParts[1]
-		log.Printf("JWT token received: %v", tokenStr)
-		secret := []byte(os.Getenv("ACCESS_TOKEN_SECRET"))
-		log.Printf("JWT secret: %v", secret)
 
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			if token.Method.Alg() != jwt.GetSigningMethod("HS256").Alg() {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return secret, nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := utils.ParseToken(keys, tokenStr, utils.AccessTokenType)
+		if err != nil {
 			log.Printf("Invalid token: %v", err)
 			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
 				"status":  "fail",
@@ -57,15 +50,6 @@ func AuthProtected(db *gorm.DB) fiber.Handler {
 			})
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			log.Println("Failed to parse token claims")
-			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
-				"status":  "fail",
-				"message": "Unauthorized",
-			})
-		}
-
 		userId, ok := claims["id"].(float64)
 		if !ok {
 			log.Println("Invalid token ID format")
@@ -92,6 +76,50 @@ func AuthProtected(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		jti, ok := claims["jti"].(string)
+		if !ok || jti == "" {
+			log.Println("Missing jti claim")
+			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "Unauthorized",
+			})
+		}
+
+		sessionID, ok := claims["sid"].(string)
+		if !ok || sessionID == "" {
+			log.Println("Missing sid claim")
+			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "Unauthorized",
+			})
+		}
+
+		blacklisted, err := redisClient.Exists(ctx.Context(), utils.AccessTokenBlacklistKey(jti), utils.SessionRevokedKey(sessionID)).Result()
+		if err != nil {
+			log.Printf("Unable to check token blacklist: %v", err)
+			return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "Internal server error",
+			})
+		}
+
+		if blacklisted > 0 {
+			log.Println("Token has been revoked")
+			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "Token has been revoked",
+			})
+		}
+
+		version, ok := claims["version"].(float64)
+		if !ok {
+			log.Println("Invalid version claim format")
+			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "Unauthorized",
+			})
+		}
+
 		var user models.User
 		if err := db.First(&user, uint(userId)).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -107,7 +135,39 @@ func AuthProtected(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		// Tokens minted before a password change or "log out everywhere" are stale
+		if int(version) != user.TokenVersion {
+			log.Println("Token version is stale")
+			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "Token has been revoked",
+			})
+		}
+
+		if adminID, adminVersion, ok := utils.Impersonator(claims); ok {
+			var admin models.User
+			if err := db.First(&admin, adminID).Error; err != nil || !admin.HasRole(models.Admin) || admin.TokenVersion != adminVersion {
+				log.Println("Impersonating admin is no longer valid")
+				return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+					"status":  "fail",
+					"message": "Token has been revoked",
+				})
+			}
+
+			ctx.Locals("impersonatorId", adminID)
+			audit.Record(ctx.Context(), &models.AuditLog{
+				Type:      models.AuditImpersonatedRequest,
+				ActorID:   &adminID,
+				TargetID:  &user.ID,
+				IPAddress: ctx.IP(),
+				UserAgent: ctx.Get(fiber.HeaderUserAgent),
+				Metadata:  map[string]interface{}{"method": ctx.Method(), "path": ctx.Path(), "session_id": sessionID},
+			})
+		}
+
 		ctx.Locals("userId", uint(userId))
+		ctx.Locals("jti", jti)
+		ctx.Locals("sessionId", sessionID)
 		log.Printf("Middleware: UserID %v authenticated successfully", userId)
 
 		return ctx.Next()
@@ -0,0 +1,18 @@
+package middlewares
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// RejectImpersonation keeps sensitive account changes, such as passwords, 2FA and deletion, to the account owner
+func RejectImpersonation() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if _, ok := ctx.Locals("impersonatorId").(uint); ok {
+			return ctx.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "This endpoint cannot be used while impersonating a user",
+			})
+		}
+		return ctx.Next()
+	}
+}
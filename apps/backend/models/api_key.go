@@ -0,0 +1,90 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKeyPrefix marks a Bearer credential as an API key rather than a JWT
+const APIKeyPrefix = "bored_pat_"
+
+var (
+	ErrInvalidAPIKey     = errors.New("invalid, expired or revoked api key")
+	ErrInsufficientScope = errors.New("api key is missing the required scope")
+)
+
+// resources an API key can be scoped to, each as "<resource>:read" or "<resource>:write"
+var APIKeyResources = []string{
+	"events",
+	"tickets",
+	"chats",
+	"notifications",
+	"moderation",
+	"users",
+	"boringspaces",
+	"public_messages",
+}
+
+type APIKey struct {
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	UserID     uint           `json:"-" gorm:"not null;index"`
+	Name       string         `json:"name" gorm:"not null"`
+	Prefix     string         `json:"prefix" gorm:"not null"`   // Leading characters shown so users can tell keys apart
+	KeyHash    string         `json:"-" gorm:"not null;unique"` // SHA-256 of the key, the raw key is only shown once
+	Scopes     pq.StringArray `json:"scopes" gorm:"type:text[];not null" swaggertype:"array,string"`
+	ExpiresAt  *time.Time     `json:"expires_at"` // nil never expires
+	LastUsedAt *time.Time     `json:"last_used_at"`
+	LastUsedIP string         `json:"last_used_ip"`
+	RevokedAt  *time.Time     `json:"revoked_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// HasScope reports whether the key grants scope. Write access implies read access.
+func (k *APIKey) HasScope(scope string) bool {
+	resource, access, _ := strings.Cut(scope, ":")
+	for _, s := range k.Scopes {
+		if s == scope || (access == "read" && s == resource+":write") {
+			return true
+		}
+	}
+	return false
+}
+
+func IsValidAPIKeyScope(scope string) bool {
+	resource, access, ok := strings.Cut(scope, ":")
+	if !ok || (access != "read" && access != "write") {
+		return false
+	}
+	for _, r := range APIKeyResources {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" validate:"required,max=64"`
+	Scopes        []string `json:"scopes" validate:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"gte=0,lte=365"` // 0 never expires
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *APIKey) error
+	GetByUser(ctx context.Context, userID uint) ([]*APIKey, error)
+	FindByHash(ctx context.Context, keyHash string) (*APIKey, error)
+	Revoke(ctx context.Context, userID uint, keyID uint) error
+	RevokeAllForUser(ctx context.Context, userID uint) error
+	TouchLastUsed(ctx context.Context, keyID uint, ip string) error
+}
+
+type APIKeyService interface {
+	CreateKey(ctx context.Context, userID uint, req *CreateAPIKeyRequest) (string, *APIKey, error)
+	GetKeys(ctx context.Context, userID uint) ([]*APIKey, error)
+	RevokeKey(ctx context.Context, userID uint, keyID uint) error
+	Authenticate(ctx context.Context, rawKey string, ip string) (*APIKey, error)
+}
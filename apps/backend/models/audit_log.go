@@ -0,0 +1,77 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+type AuditEventType string
+
+const (
+	AuditRegistered               AuditEventType = "registered"
+	AuditLoginSucceeded           AuditEventType = "login_succeeded"
+	AuditLoginFailed              AuditEventType = "login_failed"
+	AuditLogout                   AuditEventType = "logout"
+	AuditLogoutAll                AuditEventType = "logout_all"
+	AuditTokenRotated             AuditEventType = "token_rotated"
+	AuditRefreshTokenReused       AuditEventType = "refresh_token_reused"
+	AuditSessionRevoked           AuditEventType = "session_revoked"
+	AuditEmailVerified            AuditEventType = "email_verified"
+	AuditPhoneVerified            AuditEventType = "phone_verified"
+	AuditPasswordResetRequested   AuditEventType = "password_reset_requested"
+	AuditPasswordReset            AuditEventType = "password_reset"
+	AuditPasswordChanged          AuditEventType = "password_changed"
+	AuditTwoFactorEnabled         AuditEventType = "two_factor_enabled"
+	AuditTwoFactorDisabled        AuditEventType = "two_factor_disabled"
+	AuditRecoveryCodesRegenerated AuditEventType = "recovery_codes_regenerated"
+	AuditAccountLocked            AuditEventType = "account_locked"
+	AuditAccountUnlocked          AuditEventType = "account_unlocked"
+	AuditAPIKeyCreated            AuditEventType = "api_key_created"
+	AuditAPIKeyRevoked            AuditEventType = "api_key_revoked"
+	AuditOAuthLogin               AuditEventType = "oauth_login"
+	AuditOAuthUnlinked            AuditEventType = "oauth_unlinked"
+	AuditRoleChanged              AuditEventType = "role_changed"
+	AuditSpaceRoleSaved           AuditEventType = "space_role_saved"
+	AuditSpaceRoleDeleted         AuditEventType = "space_role_deleted"
+	AuditSpaceOwnerChanged        AuditEventType = "space_owner_changed"
+	AuditSpaceDeleted             AuditEventType = "space_deleted"
+	AuditUserDeactivated          AuditEventType = "user_deactivated"
+	AuditUserDeleted              AuditEventType = "user_deleted"
+	AuditImpersonationStarted     AuditEventType = "impersonation_started"
+	AuditImpersonationEnded       AuditEventType = "impersonation_ended"
+	AuditImpersonatedRequest      AuditEventType = "impersonated_request"
+)
+
+// AuditLog is an append-only record of a security relevant event. Actor is who did it,
+// target whose account it concerned; both are nil when unknown, e.g. a login for a missing user.
+type AuditLog struct {
+	ID        uint                   `json:"id" gorm:"primaryKey"`
+	Type      AuditEventType         `json:"type" gorm:"not null;index"`
+	ActorID   *uint                  `json:"actor_id" gorm:"index"`
+	TargetID  *uint                  `json:"target_id" gorm:"index"`
+	IPAddress string                 `json:"ip_address"`
+	UserAgent string                 `json:"user_agent"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty" gorm:"serializer:json;type:jsonb"`
+	CreatedAt time.Time              `json:"created_at" gorm:"index"`
+}
+
+type AuditLogFilter struct {
+	UserID *uint // matches either actor or target
+	Type   AuditEventType
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+	Offset int
+}
+
+type AuditLogRepository interface {
+	Create(ctx context.Context, entry *AuditLog) error
+	Find(ctx context.Context, filter *AuditLogFilter) ([]*AuditLog, error)
+}
+
+type AuditService interface {
+	// Record never fails the caller, a lost audit entry is logged instead
+	Record(ctx context.Context, entry *AuditLog)
+	Query(ctx context.Context, filter *AuditLogFilter) ([]*AuditLog, error)
+	RecentActivity(ctx context.Context, userID uint, limit int) ([]*AuditLog, error)
+}
@@ -2,10 +2,10 @@ package models
 
 import (
 	"context"
+	"errors"
 	"net/mail"
+	"regexp"
 	"time"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthCredentials struct {
@@ -16,32 +16,113 @@ type AuthCredentials struct {
 	TwoFACode   string `json:"two_fa_code,omitempty"`
 }
 
+var (
+	ErrTwoFactorRequired = errors.New("two-factor code required")
+	ErrInvalidTwoFACode  = errors.New("invalid two-factor code")
+	ErrInvalidChallenge  = errors.New("invalid or expired two-factor challenge")
+	ErrTooManyRequests   = errors.New("too many requests, please try again later")
+	ErrInvalidPhoneCode  = errors.New("invalid or expired verification code")
+	ErrPhoneNumberTaken  = errors.New("phone number is already in use")
+	ErrAccountLocked     = errors.New("too many failed attempts, please try again later")
+
+	ErrImpersonationNotAllowed = errors.New("admins cannot impersonate themselves or other admins")
+	ErrNotImpersonating        = errors.New("this session is not impersonating a user")
+)
+
+// scopes tracked separately by the brute-force lockout
+type LockoutScope string
+
+const (
+	LoginLockout     LockoutScope = "login"
+	TwoFactorLockout LockoutScope = "2fa"
+	PhoneLockout     LockoutScope = "phone"
+)
+
+var LockoutScopes = []LockoutScope{LoginLockout, TwoFactorLockout, PhoneLockout}
+
+// LockoutError is ErrAccountLocked with the time left on the lock
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return ErrAccountLocked.Error()
+}
+
+func (e *LockoutError) Unwrap() error {
+	return ErrAccountLocked
+}
+
+// TwoFactorChallengeError is ErrTwoFactorRequired for logins that can't resend their credentials,
+// like social logins. The client finishes the login by sending Challenge with a 2FA code.
+type TwoFactorChallengeError struct {
+	Challenge string
+}
+
+func (e *TwoFactorChallengeError) Error() string {
+	return ErrTwoFactorRequired.Error()
+}
+
+func (e *TwoFactorChallengeError) Unwrap() error {
+	return ErrTwoFactorRequired
+}
+
+// returned when enrolling an authenticator app
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	QRCode          []byte `json:"qrcode"` // PNG of the provisioning URI
+}
+
+// ImpersonationToken is a short-lived access token for support staff, there is no refresh token
+type ImpersonationToken struct {
+	AccessToken    string    `json:"access_token"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	UserID         uint      `json:"user_id"`
+	ImpersonatorID uint      `json:"impersonator_id"`
+}
+
 // user registration and retrieval
 type AuthRepository interface {
 	RegisterUser(ctx context.Context, registerData *AuthCredentials) (*User, error)
 	GetUser(ctx context.Context, query interface{}, args ...interface{}) (*User, error)
-	VerifyPhoneNumber(ctx context.Context, userID uint, code string) error
+	MarkPhoneVerified(ctx context.Context, userID uint, phoneNumber string) error
 	VerifyEmail(ctx context.Context, userID uint) error
+	UpdatePassword(ctx context.Context, userID uint, passwordHash string) error
 }
 
 // for login, registration, and verification
 type AuthService interface {
-	Login(ctx context.Context, loginData *AuthCredentials) (map[string]string, *User, error)
-	Register(ctx context.Context, registerData *AuthCredentials) (string, *User, error)
-	EnableTwoFactor(ctx context.Context, userID uint) error
+	Login(ctx context.Context, loginData *AuthCredentials, device *DeviceInfo) (map[string]string, *User, error)
+	Register(ctx context.Context, registerData *AuthCredentials, device *DeviceInfo) (map[string]string, *User, error)
+	SetupTwoFactor(ctx context.Context, userID uint) (*TwoFactorSetup, error)
+	ConfirmTwoFactor(ctx context.Context, userID uint, code string) ([]string, error)
+	DisableTwoFactor(ctx context.Context, userID uint, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error)
+	RequestPhoneVerification(ctx context.Context, userID uint, phoneNumber string) error
 	VerifyPhoneNumber(ctx context.Context, userID uint, code string) error
-	VerifyEmail(ctx context.Context, userID uint) error
-	IsValidTwoFACode(twoFACode string) bool
-	Logout(ctx context.Context, refreshToken string) error
-	RotateRefreshToken(ctx context.Context, oldRefreshToken string) (map[string]string, error)
-	BlacklistAccessToken(ctx context.Context, accessToken string, expiry time.Duration) error
+	VerifyEmail(ctx context.Context, token string, device *DeviceInfo) error
+	SendVerificationEmail(ctx context.Context, user *User) error
+	ResendVerificationEmail(ctx context.Context, email string) error
+	IsValidTwoFACode(ctx context.Context, user *User, twoFACode string) (bool, error)
+	LoginExternal(ctx context.Context, user *User, device *DeviceInfo) (map[string]string, error)
+	CompleteTwoFactorChallenge(ctx context.Context, challenge string, code string, device *DeviceInfo) (map[string]string, *User, error)
+	Logout(ctx context.Context, refreshToken string, device *DeviceInfo) error
+	RotateRefreshToken(ctx context.Context, oldRefreshToken string, device *DeviceInfo) (map[string]string, error)
+	ListSessions(ctx context.Context, userID uint, currentSessionID string) ([]*Session, error)
+	RevokeSession(ctx context.Context, userID uint, sessionID string) error
+	RevokeOtherSessions(ctx context.Context, userID uint, currentSessionID string) error
+	LogoutAll(ctx context.Context, userID uint) error
+	ForgotPassword(ctx context.Context, email string, device *DeviceInfo) error
+	ResetPassword(ctx context.Context, token string, newPassword string, device *DeviceInfo) error
+	ChangePassword(ctx context.Context, userID uint, currentPassword string, newPassword string) error
+	BlacklistAccessToken(ctx context.Context, jti string, expiry time.Duration) error
+	RevokeAccessToken(ctx context.Context, accessToken string) error
 	GetUserDataFromToken(ctx context.Context, token string) (*User, error)
-}
-
-// Check if a password matches a hash
-func MatchesHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	UnlockAccount(ctx context.Context, userID uint) error
+	UnlockIP(ctx context.Context, ip string) error
+	Impersonate(ctx context.Context, adminID uint, targetID uint, reason string, device *DeviceInfo) (*ImpersonationToken, error)
+	EndImpersonation(ctx context.Context, sessionID string, adminID uint, userID uint, device *DeviceInfo) error
 }
 
 // Checks if an email is valid
@@ -49,3 +130,10 @@ func IsValidEmail(email string) bool {
 	_, err := mail.ParseAddress(email)
 	return err == nil
 }
+
+var phoneNumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// Checks if a phone number is in E.164 format
+func IsValidPhoneNumber(phoneNumber string) bool {
+	return phoneNumberPattern.MatchString(phoneNumber)
+}
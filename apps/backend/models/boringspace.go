@@ -2,7 +2,11 @@ package models
 
 import (
 	"context"
+	"errors"
+	"regexp"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type BoringSpaceRole string
@@ -14,15 +18,132 @@ const (
 	BSModerator BoringSpaceRole = "moderator"
 )
 
+var (
+	ErrUnknownSpaceRole       = errors.New("role is not defined in this space")
+	ErrInvalidSpaceRoleName   = errors.New("role names must be 1-32 lowercase letters, digits, '-' or '_'")
+	ErrInvalidSpacePermission = errors.New("unknown space permission")
+	ErrSpaceAdminRoleFixed    = errors.New("the admin role always has every permission")
+	ErrSpaceRoleInUse         = errors.New("role is still assigned to members")
+	ErrNotSpaceMember         = errors.New("user is not a member of this space")
+	ErrLastSpaceAdmin         = errors.New("a space needs at least one admin, promote someone else first")
+	ErrSpaceOwner             = errors.New("the owner can't leave, be removed or be demoted, transfer ownership first")
+)
+
+// higher ranks include every permission of the lower ones
+var boringSpaceRoleRank = map[BoringSpaceRole]int{
+	BSViewer:    1,
+	BSMember:    2,
+	BSModerator: 3,
+	BSAdmin:     4,
+}
+
+var spaceRoleNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+func (r BoringSpaceRole) IsBuiltin() bool {
+	_, ok := boringSpaceRoleRank[r]
+	return ok
+}
+
+func (r BoringSpaceRole) IsValidName() bool {
+	return spaceRoleNamePattern.MatchString(string(r))
+}
+
+// AtLeast reports whether r ranks at or above min. Custom roles rank alongside members.
+func (r BoringSpaceRole) AtLeast(min BoringSpaceRole) bool {
+	return r.rank() >= min.rank()
+}
+
+func (r BoringSpaceRole) rank() int {
+	if rank, ok := boringSpaceRoleRank[r]; ok {
+		return rank
+	}
+	if r == "" {
+		return 0
+	}
+	return boringSpaceRoleRank[BSMember]
+}
+
+type BoringSpacePermission string
+
+const (
+	SpacePermPost         BoringSpacePermission = "post"
+	SpacePermComment      BoringSpacePermission = "comment"
+	SpacePermInvite       BoringSpacePermission = "invite"
+	SpacePermKick         BoringSpacePermission = "kick"
+	SpacePermChangeRoles  BoringSpacePermission = "change_roles"
+	SpacePermPin          BoringSpacePermission = "pin"
+	SpacePermModerate     BoringSpacePermission = "moderate"
+	SpacePermManageEvents BoringSpacePermission = "manage_events"
+)
+
+var BoringSpacePermissions = []BoringSpacePermission{
+	SpacePermPost,
+	SpacePermComment,
+	SpacePermInvite,
+	SpacePermKick,
+	SpacePermChangeRoles,
+	SpacePermPin,
+	SpacePermModerate,
+	SpacePermManageEvents,
+}
+
+// DefaultSpacePermissions applies to built-in roles until a space admin overrides them
+var DefaultSpacePermissions = map[BoringSpaceRole][]BoringSpacePermission{
+	BSViewer:    {},
+	BSMember:    {SpacePermPost, SpacePermComment},
+	BSModerator: {SpacePermPost, SpacePermComment, SpacePermInvite, SpacePermKick, SpacePermPin, SpacePermModerate, SpacePermManageEvents},
+	BSAdmin:     BoringSpacePermissions,
+}
+
+func (p BoringSpacePermission) IsValid() bool {
+	for _, perm := range BoringSpacePermissions {
+		if perm == p {
+			return true
+		}
+	}
+	return false
+}
+
+type BoringSpaceVisibility string
+
+const (
+	SpacePublic   BoringSpaceVisibility = "public"   // listed in the directory and search
+	SpaceUnlisted BoringSpaceVisibility = "unlisted" // visible to anyone with the ID, but not listed
+	SpacePrivate  BoringSpaceVisibility = "private"  // hidden from non-members
+)
+
+func (v BoringSpaceVisibility) IsValid() bool {
+	return v == SpacePublic || v == SpaceUnlisted || v == SpacePrivate
+}
+
 type BoringSpace struct {
-	ID          uint                `json:"id" gorm:"primarykey"`
-	Name        string              `json:"name" gorm:"text;not null;unique"`
-	Description string              `json:"description" gorm:"text"`
-	CreatorID   uint                `json:"creator_id" gorm:"not null"`
-	Creator     User                `json:"creator" gorm:"foreignkey:CreatorID"`
-	Members     []BoringSpaceMember `json:"members" gorm:"foreignKey:BoringSpaceID"`
-	CreatedAt   time.Time           `json:"created_at" gorm:"default:now()"`
-	UpdatedAt   time.Time           `json:"updated_at" gorm:"default:now()"`
+	ID             uint                  `json:"id" gorm:"primarykey"`
+	Name           string                `json:"name" gorm:"text;not null;unique"`
+	Description    string                `json:"description" gorm:"text"`
+	Visibility     BoringSpaceVisibility `json:"visibility" gorm:"type:text;not null;default:'public';index"`
+	CreatorID      uint                  `json:"creator_id" gorm:"not null"`
+	Creator        User                  `json:"creator" gorm:"foreignkey:CreatorID"`
+	Members        []BoringSpaceMember   `json:"members,omitempty" gorm:"foreignKey:BoringSpaceID"`
+	MemberCount    int64                 `json:"member_count" gorm:"->;-:migration"` // only filled in by the directory
+	LastActivityAt time.Time             `json:"last_activity_at" gorm:"not null;default:now()"`
+	CreatedAt      time.Time             `json:"created_at" gorm:"default:now()"`
+	UpdatedAt      time.Time             `json:"updated_at" gorm:"default:now()"`
+}
+
+type BoringSpaceSort string
+
+const (
+	SortByMembers  BoringSpaceSort = "members"
+	SortByActivity BoringSpaceSort = "activity"
+	SortByNewest   BoringSpaceSort = "newest"
+)
+
+// BoringSpaceFilter pages through the public directory, optionally searching name and description
+type BoringSpaceFilter struct {
+	Query  string
+	Sort   BoringSpaceSort
+	Limit  int
+	Offset int
 }
 
 type BoringSpaceMember struct {
@@ -34,20 +155,86 @@ type BoringSpaceMember struct {
 	JoinedAt      time.Time       `json:"joined_at" gorm:"default:now()"`
 }
 
+// BoringSpaceRoleDefinition stores a space's permissions for a role, either overriding
+// a built-in role's defaults or defining a custom role
+type BoringSpaceRoleDefinition struct {
+	ID            uint            `json:"-" gorm:"primaryKey"`
+	BoringSpaceID uint            `json:"boringspace_id" gorm:"not null;uniqueIndex:idx_space_role"`
+	Name          BoringSpaceRole `json:"name" gorm:"type:text;not null;uniqueIndex:idx_space_role"`
+	Permissions   pq.StringArray  `json:"permissions" gorm:"type:text[];not null" swaggertype:"array,string"`
+	Builtin       bool            `json:"builtin" gorm:"-"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+func (d *BoringSpaceRoleDefinition) HasPermission(perm BoringSpacePermission) bool {
+	for _, p := range d.Permissions {
+		if BoringSpacePermission(p) == perm {
+			return true
+		}
+	}
+	return false
+}
+
+type SaveSpaceRoleRequest struct {
+	Permissions []BoringSpacePermission `json:"permissions" validate:"required"`
+}
+
+// Outranks reports whether m may grant role or act on a member holding it.
+// Admins can manage anyone, everyone else only roles ranked below their own.
+func (m *BoringSpaceMember) Outranks(role BoringSpaceRole) bool {
+	return m.Role == BSAdmin || !role.AtLeast(m.Role)
+}
+
+type UpdateBoringSpaceRequest struct {
+	Name        *string                `json:"name" validate:"omitempty,min=1,max=100"`
+	Description *string                `json:"description" validate:"omitempty,max=2000"`
+	Visibility  *BoringSpaceVisibility `json:"visibility"`
+}
+
 type BoringSpaceRepository interface {
+	// CreateBoringSpace also makes the creator an admin member, in the same transaction
 	CreateBoringSpace(ctx context.Context, space *BoringSpace) error
+	UpdateBoringSpace(ctx context.Context, space *BoringSpace) error
+	// DeleteBoringSpace removes the space with everything that belongs to it
+	DeleteBoringSpace(ctx context.Context, spaceID uint) error
+	// TransferOwnership makes newOwnerID, who must be a member, the creator and an admin
+	TransferOwnership(ctx context.Context, spaceID uint, newOwnerID uint) error
 	GetBoringSpaceByID(ctx context.Context, spaceID uint) (*BoringSpace, error)
+	GetVisibility(ctx context.Context, spaceID uint) (BoringSpaceVisibility, error)
+	ListPublic(ctx context.Context, filter *BoringSpaceFilter) ([]*BoringSpace, error)
+	TouchActivity(ctx context.Context, spaceID uint) error
 	AddMember(ctx context.Context, member *BoringSpaceMember) error
+	// RemoveMember and UpdateMemberRole fail with ErrLastSpaceAdmin rather than leave a space without admins
 	RemoveMember(ctx context.Context, spaceID uint, userID uint) error
 	GetMembers(ctx context.Context, spaceID uint) ([]*BoringSpaceMember, error)
+	GetMember(ctx context.Context, spaceID uint, userID uint) (*BoringSpaceMember, error)
 	UpdateMemberRole(ctx context.Context, spaceID uint, userID uint, role BoringSpaceRole) error
+	CountMembersWithRole(ctx context.Context, spaceID uint, role BoringSpaceRole) (int64, error)
+	GetRoleDefinitions(ctx context.Context, spaceID uint) ([]*BoringSpaceRoleDefinition, error)
+	GetRoleDefinition(ctx context.Context, spaceID uint, name BoringSpaceRole) (*BoringSpaceRoleDefinition, error)
+	SaveRoleDefinition(ctx context.Context, definition *BoringSpaceRoleDefinition) error
+	DeleteRoleDefinition(ctx context.Context, spaceID uint, name BoringSpaceRole) error
 }
 
 type BoringSpaceService interface {
 	CreateBoringSpace(ctx context.Context, space *BoringSpace) error
 	GetBoringSpaceByID(ctx context.Context, spaceID uint) (*BoringSpace, error)
-	AddMember(ctx context.Context, member *BoringSpaceMember) error
-	RemoveMember(ctx context.Context, spaceID uint, userID uint) error
+	// CanView reports whether userID may see the space at all, private spaces only exist for their members
+	CanView(ctx context.Context, spaceID uint, userID uint) (bool, error)
+	Directory(ctx context.Context, filter *BoringSpaceFilter) ([]*BoringSpace, error)
+	TouchActivity(ctx context.Context, spaceID uint) error
+	UpdateBoringSpace(ctx context.Context, spaceID uint, req *UpdateBoringSpaceRequest) (*BoringSpace, error)
+	DeleteBoringSpace(ctx context.Context, spaceID uint) error
+	TransferOwnership(ctx context.Context, spaceID uint, newOwnerID uint) error
+	LeaveBoringSpace(ctx context.Context, spaceID uint, userID uint) error
+	RemoveMember(ctx context.Context, spaceID uint, userID uint, moderatorID uint) error
 	GetMembers(ctx context.Context, spaceID uint) ([]*BoringSpaceMember, error)
+	GetMember(ctx context.Context, spaceID uint, userID uint) (*BoringSpaceMember, error)
 	UpdateMemberRole(ctx context.Context, spaceID uint, userID uint, role BoringSpaceRole) error
+	GetRoles(ctx context.Context, spaceID uint) ([]*BoringSpaceRoleDefinition, error)
+	SaveRole(ctx context.Context, spaceID uint, name BoringSpaceRole, permissions []BoringSpacePermission) (*BoringSpaceRoleDefinition, error)
+	DeleteRole(ctx context.Context, spaceID uint, name BoringSpaceRole) error
+	ValidateRole(ctx context.Context, spaceID uint, role BoringSpaceRole) error
+	HasPermission(ctx context.Context, member *BoringSpaceMember, perm BoringSpacePermission) (bool, error)
 }
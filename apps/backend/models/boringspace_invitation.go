@@ -0,0 +1,125 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrAlreadySpaceMember  = errors.New("user is already a member of this space")
+	ErrAlreadyInvited      = errors.New("user already has a pending invitation to this space")
+	ErrInvitationClosed    = errors.New("invitation is no longer pending")
+	ErrInvalidInviteCode   = errors.New("invite code is invalid, expired or used up")
+	ErrJoinRequestPending  = errors.New("a join request for this space is already pending")
+	ErrJoinRequestReviewed = errors.New("join request has already been reviewed")
+)
+
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationAccepted InvitationStatus = "accepted"
+	InvitationDeclined InvitationStatus = "declined"
+	InvitationRevoked  InvitationStatus = "revoked"
+)
+
+// BoringSpaceInvitation invites a single user, who can accept or decline it
+type BoringSpaceInvitation struct {
+	ID            uint             `json:"id" gorm:"primaryKey"`
+	BoringSpaceID uint             `json:"boringspace_id" gorm:"not null;index"`
+	BoringSpace   *BoringSpace     `json:"boringspace,omitempty" gorm:"foreignKey:BoringSpaceID"`
+	InviterID     uint             `json:"inviter_id" gorm:"not null"`
+	InviteeID     uint             `json:"invitee_id" gorm:"not null;index"`
+	Role          BoringSpaceRole  `json:"role" gorm:"type:text;not null;default:'member'"`
+	Status        InvitationStatus `json:"status" gorm:"type:text;not null;default:'pending'"`
+	RespondedAt   *time.Time       `json:"responded_at"`
+	CreatedAt     time.Time        `json:"created_at"`
+}
+
+// BoringSpaceInviteCode is a shareable link anyone holding it can join with
+type BoringSpaceInviteCode struct {
+	ID            uint            `json:"id" gorm:"primaryKey"`
+	BoringSpaceID uint            `json:"boringspace_id" gorm:"not null;index"`
+	Code          string          `json:"code" gorm:"not null;unique"`
+	CreatorID     uint            `json:"creator_id" gorm:"not null"`
+	Role          BoringSpaceRole `json:"role" gorm:"type:text;not null;default:'member'"`
+	MaxUses       int             `json:"max_uses" gorm:"not null;default:0"` // 0 is unlimited
+	Uses          int             `json:"uses" gorm:"not null;default:0"`
+	ExpiresAt     *time.Time      `json:"expires_at"` // nil never expires
+	RevokedAt     *time.Time      `json:"revoked_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+type JoinRequestStatus string
+
+const (
+	JoinRequestPending  JoinRequestStatus = "pending"
+	JoinRequestApproved JoinRequestStatus = "approved"
+	JoinRequestRejected JoinRequestStatus = "rejected"
+)
+
+// BoringSpaceJoinRequest asks the space's admins to let a user in
+type BoringSpaceJoinRequest struct {
+	ID            uint              `json:"id" gorm:"primaryKey"`
+	BoringSpaceID uint              `json:"boringspace_id" gorm:"not null;index"`
+	UserID        uint              `json:"user_id" gorm:"not null;index"`
+	User          *User             `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Message       string            `json:"message"`
+	Status        JoinRequestStatus `json:"status" gorm:"type:text;not null;default:'pending'"`
+	ReviewerID    *uint             `json:"reviewer_id"`
+	ReviewedAt    *time.Time        `json:"reviewed_at"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+type CreateInvitationRequest struct {
+	UserID uint            `json:"user_id" validate:"required"`
+	Role   BoringSpaceRole `json:"role"` // defaults to member
+}
+
+type CreateInviteCodeRequest struct {
+	Role          BoringSpaceRole `json:"role"`                             // defaults to member
+	MaxUses       int             `json:"max_uses" validate:"gte=0"`        // 0 is unlimited
+	ExpiresInDays int             `json:"expires_in_days" validate:"gte=0"` // 0 never expires
+}
+
+type BoringSpaceInvitationRepository interface {
+	CreateInvitation(ctx context.Context, invitation *BoringSpaceInvitation) error
+	GetInvitation(ctx context.Context, invitationID uint) (*BoringSpaceInvitation, error)
+	GetPendingInvitation(ctx context.Context, spaceID uint, inviteeID uint) (*BoringSpaceInvitation, error)
+	GetSpaceInvitations(ctx context.Context, spaceID uint) ([]*BoringSpaceInvitation, error)
+	GetUserInvitations(ctx context.Context, userID uint) ([]*BoringSpaceInvitation, error)
+	// CloseInvitation moves a pending invitation to status, adding member in the same transaction if given
+	CloseInvitation(ctx context.Context, invitationID uint, status InvitationStatus, member *BoringSpaceMember) error
+
+	CreateInviteCode(ctx context.Context, code *BoringSpaceInviteCode) error
+	GetInviteCodes(ctx context.Context, spaceID uint) ([]*BoringSpaceInviteCode, error)
+	GetInviteCode(ctx context.Context, code string) (*BoringSpaceInviteCode, error)
+	RevokeInviteCode(ctx context.Context, spaceID uint, codeID uint) error
+	// RedeemInviteCode uses up one use of a live code and adds the member in one transaction
+	RedeemInviteCode(ctx context.Context, code string, userID uint) (*BoringSpaceInviteCode, *BoringSpaceMember, error)
+
+	CreateJoinRequest(ctx context.Context, request *BoringSpaceJoinRequest) error
+	GetJoinRequest(ctx context.Context, requestID uint) (*BoringSpaceJoinRequest, error)
+	GetPendingJoinRequest(ctx context.Context, spaceID uint, userID uint) (*BoringSpaceJoinRequest, error)
+	GetJoinRequests(ctx context.Context, spaceID uint) ([]*BoringSpaceJoinRequest, error)
+	// ReviewJoinRequest closes a pending request, adding member in the same transaction if given
+	ReviewJoinRequest(ctx context.Context, requestID uint, status JoinRequestStatus, reviewerID uint, member *BoringSpaceMember) error
+}
+
+type BoringSpaceInvitationService interface {
+	Invite(ctx context.Context, spaceID uint, inviterID uint, req *CreateInvitationRequest) (*BoringSpaceInvitation, error)
+	GetSpaceInvitations(ctx context.Context, spaceID uint) ([]*BoringSpaceInvitation, error)
+	GetUserInvitations(ctx context.Context, userID uint) ([]*BoringSpaceInvitation, error)
+	RespondToInvitation(ctx context.Context, invitationID uint, userID uint, accept bool) (*BoringSpaceInvitation, error)
+	RevokeInvitation(ctx context.Context, spaceID uint, invitationID uint) error
+
+	CreateInviteCode(ctx context.Context, spaceID uint, creatorID uint, req *CreateInviteCodeRequest) (*BoringSpaceInviteCode, error)
+	GetInviteCodes(ctx context.Context, spaceID uint) ([]*BoringSpaceInviteCode, error)
+	RevokeInviteCode(ctx context.Context, spaceID uint, codeID uint) error
+	JoinWithCode(ctx context.Context, code string, userID uint) (*BoringSpaceMember, error)
+
+	RequestToJoin(ctx context.Context, spaceID uint, userID uint, message string) (*BoringSpaceJoinRequest, error)
+	GetJoinRequests(ctx context.Context, spaceID uint) ([]*BoringSpaceJoinRequest, error)
+	ReviewJoinRequest(ctx context.Context, spaceID uint, requestID uint, reviewerID uint, approve bool) (*BoringSpaceJoinRequest, error)
+}
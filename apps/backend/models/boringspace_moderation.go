@@ -0,0 +1,100 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// MaxSpaceTimeout caps how long a timeout can last, longer punishments are mutes or bans
+const MaxSpaceTimeout = 28 * 24 * time.Hour
+
+var (
+	ErrSpaceBanned          = errors.New("user is banned from this space")
+	ErrTimeoutDuration      = errors.New("timeouts need a duration of at most 28 days")
+	ErrCannotModerateSelf   = errors.New("you can't moderate yourself")
+	ErrSpaceSanctionMissing = errors.New("user has no active sanction of this kind")
+)
+
+type SpaceSanctionType string
+
+const (
+	SpaceBan     SpaceSanctionType = "ban"
+	SpaceMute    SpaceSanctionType = "mute"
+	SpaceTimeout SpaceSanctionType = "timeout"
+)
+
+// Blocks reports whether an active sanction of this type takes perm away. Mutes stop posting and
+// commenting, timeouts stop everything but reading. Bans remove the member altogether.
+func (t SpaceSanctionType) Blocks(perm BoringSpacePermission) bool {
+	switch t {
+	case SpaceTimeout:
+		return true
+	case SpaceMute:
+		return perm == SpacePermPost || perm == SpacePermComment
+	}
+	return false
+}
+
+// BoringSpaceSanction is a ban, mute or timeout. It stops applying once it expires or is lifted.
+type BoringSpaceSanction struct {
+	ID            uint              `json:"id" gorm:"primaryKey"`
+	BoringSpaceID uint              `json:"boringspace_id" gorm:"not null;index:idx_space_sanction"`
+	UserID        uint              `json:"user_id" gorm:"not null;index:idx_space_sanction"`
+	User          *User             `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Type          SpaceSanctionType `json:"type" gorm:"type:text;not null"`
+	Reason        string            `json:"reason"`
+	ModeratorID   uint              `json:"moderator_id" gorm:"not null"`
+	ExpiresAt     *time.Time        `json:"expires_at"` // nil lasts until lifted
+	LiftedAt      *time.Time        `json:"lifted_at,omitempty"`
+	LiftedByID    *uint             `json:"lifted_by_id,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+type SpaceModerationAction string
+
+const (
+	SpaceActionKick          SpaceModerationAction = "kick"
+	SpaceActionBan           SpaceModerationAction = "ban"
+	SpaceActionUnban         SpaceModerationAction = "unban"
+	SpaceActionMute          SpaceModerationAction = "mute"
+	SpaceActionUnmute        SpaceModerationAction = "unmute"
+	SpaceActionTimeout       SpaceModerationAction = "timeout"
+	SpaceActionRemoveTimeout SpaceModerationAction = "remove_timeout"
+)
+
+// BoringSpaceModerationLog is the space's own record of moderator actions, readable by its moderators
+type BoringSpaceModerationLog struct {
+	ID            uint                  `json:"id" gorm:"primaryKey"`
+	BoringSpaceID uint                  `json:"boringspace_id" gorm:"not null;index"`
+	ModeratorID   uint                  `json:"moderator_id" gorm:"not null"`
+	Moderator     *User                 `json:"moderator,omitempty" gorm:"foreignKey:ModeratorID"`
+	TargetUserID  uint                  `json:"target_user_id" gorm:"not null"`
+	TargetUser    *User                 `json:"target_user,omitempty" gorm:"foreignKey:TargetUserID"`
+	Action        SpaceModerationAction `json:"action" gorm:"type:text;not null"`
+	Reason        string                `json:"reason"`
+	ExpiresAt     *time.Time            `json:"expires_at,omitempty"`
+	CreatedAt     time.Time             `json:"created_at"`
+}
+
+type SanctionRequest struct {
+	Reason          string `json:"reason" validate:"max=500"`
+	DurationMinutes int    `json:"duration_minutes" validate:"gte=0"` // 0 lasts until lifted, timeouts need one
+}
+
+type BoringSpaceModerationRepository interface {
+	// AddSanction replaces any active sanction of the same type, and for bans also removes the membership
+	AddSanction(ctx context.Context, sanction *BoringSpaceSanction, entry *BoringSpaceModerationLog) error
+	LiftSanction(ctx context.Context, spaceID uint, userID uint, sanctionType SpaceSanctionType, entry *BoringSpaceModerationLog) error
+	GetActiveSanctions(ctx context.Context, spaceID uint, userID uint) ([]*BoringSpaceSanction, error)
+	GetSpaceSanctions(ctx context.Context, spaceID uint) ([]*BoringSpaceSanction, error)
+	CreateLogEntry(ctx context.Context, entry *BoringSpaceModerationLog) error
+	GetLog(ctx context.Context, spaceID uint, limit, offset int) ([]*BoringSpaceModerationLog, error)
+}
+
+type BoringSpaceModerationService interface {
+	Sanction(ctx context.Context, spaceID uint, userID uint, moderatorID uint, sanctionType SpaceSanctionType, req *SanctionRequest) (*BoringSpaceSanction, error)
+	Lift(ctx context.Context, spaceID uint, userID uint, moderatorID uint, sanctionType SpaceSanctionType) error
+	GetSanctions(ctx context.Context, spaceID uint) ([]*BoringSpaceSanction, error)
+	GetLog(ctx context.Context, spaceID uint, limit, offset int) ([]*BoringSpaceModerationLog, error)
+}
@@ -2,14 +2,31 @@ package models
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+var (
+	ErrChannelArchived = errors.New("channel is archived")
+	ErrChannelReadOnly = errors.New("only moderators and admins can post in announcement channels")
+)
+
+// Chat is a direct or group conversation. Group chats with a BoringSpaceID are that space's channels,
+// which have no ChatMembers of their own, access follows the space's members and roles instead.
 type Chat struct {
-	ID        uint      `json:"id" gorm:"primarykey"`
-	Name      string    `json:"name"`
-	IsGroup   bool      `json:"is_group" gorm:"default:false"`
-	CreatedAt time.Time `json:"created_at" gorm:"default:now()"`
+	ID            uint            `json:"id" gorm:"primarykey"`
+	Name          string          `json:"name"`
+	IsGroup       bool            `json:"is_group" gorm:"default:false"`
+	BoringSpaceID *uint           `json:"boringspace_id,omitempty" gorm:"index"`
+	MinRole       BoringSpaceRole `json:"min_role,omitempty" gorm:"type:text"` // lowest space role that can see the channel
+	Announcement  bool            `json:"announcement,omitempty" gorm:"default:false"`
+	ArchivedAt    *time.Time      `json:"archived_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at" gorm:"default:now()"`
+}
+
+// VisibleTo reports whether a space member can read the channel
+func (c *Chat) VisibleTo(member *BoringSpaceMember) bool {
+	return member.Role.AtLeast(c.MinRole)
 }
 
 type ChatMember struct {
@@ -26,9 +43,30 @@ type Message struct {
 	CreatedAt time.Time `json:"created_at" gorm:"default:now()"`
 }
 
+type CreateChannelRequest struct {
+	Name         string          `json:"name" validate:"required,max=64"`
+	Announcement bool            `json:"announcement"`
+	MinRole      BoringSpaceRole `json:"min_role"` // defaults to viewer
+}
+
+func (m *Message) Cursor() Cursor {
+	return Cursor{CreatedAt: m.CreatedAt, ID: m.ID}
+}
+
 type ChatRepository interface {
 	CreateChat(ctx context.Context, chat *Chat) error
+	GetChat(ctx context.Context, chatID uint) (*Chat, error)
+	GetSpaceChats(ctx context.Context, spaceID uint, includeArchived bool) ([]*Chat, error)
+	ArchiveChat(ctx context.Context, chatID uint) error
 	AddMember(ctx context.Context, chatID uint, userID uint) error
-	GetMessages(ctx context.Context, chatID uint) ([]*Message, error)
+	GetMessages(ctx context.Context, chatID uint, page *PageRequest) ([]*Message, string, error)
 	SendMessage(ctx context.Context, message *Message) error
 }
+
+type BoringSpaceChannelService interface {
+	CreateChannel(ctx context.Context, spaceID uint, req *CreateChannelRequest) (*Chat, error)
+	GetChannels(ctx context.Context, member *BoringSpaceMember, includeArchived bool) ([]*Chat, error)
+	ArchiveChannel(ctx context.Context, spaceID uint, chatID uint) error
+	GetMessages(ctx context.Context, member *BoringSpaceMember, chatID uint, page *PageRequest) ([]*Message, string, error)
+	SendMessage(ctx context.Context, member *BoringSpaceMember, chatID uint, content string) (*Message, error)
+}
@@ -11,6 +11,8 @@ type Event struct {
 	ID                    uint      `json:"id" gorm:"primarykey;autoIncrement"`
 	Name                  string    `json:"name"`
 	Location              string    `json:"location"`
+	CreatorID             *uint     `json:"creatorId,omitempty"`
+	BoringSpaceID         *uint     `json:"boringSpaceId,omitempty" gorm:"index"` // Set for events on a space's calendar
 	TotalTicketsPurchased int64     `json:"totalTicketsPurchased" gorm:"-"`
 	TotalTicketsEntered   int64     `json:"totalTicketsEntered" gorm:"-"`
 	Date                  time.Time `json:"date"`
@@ -20,6 +22,8 @@ type Event struct {
 
 type EventRepository interface {
 	GetMany(ctx context.Context) ([]*Event, error)
+	// GetSpaceEvents returns the space's events dated within [from, to), soonest first
+	GetSpaceEvents(ctx context.Context, spaceID uint, from, to time.Time) ([]*Event, error)
 	GetOne(ctx context.Context, eventId uint) (*Event, error)
 	CreateOne(ctx context.Context, event *Event) (*Event, error)
 	UpdateOne(ctx context.Context, eventId uint, updateData map[string]interface{}) (*Event, error)
@@ -0,0 +1,14 @@
+package models
+
+import "context"
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// delivers transactional email (verification, password reset, notices)
+type Mailer interface {
+	Send(ctx context.Context, mail *Mail) error
+}
@@ -13,13 +13,17 @@ type Notification struct {
 	CreatedAt time.Time `json:"created_at" gorm:"default:now()"`
 }
 
+func (n *Notification) Cursor() Cursor {
+	return Cursor{CreatedAt: n.CreatedAt, ID: n.ID}
+}
+
 type NotificationRepository interface {
 	Create(ctx context.Context, notification *Notification) error
 	MarkAsRead(ctx context.Context, notificationID uint) error
-	GetNotifications(ctx context.Context, userID uint) ([]*Notification, error)
+	GetNotifications(ctx context.Context, userID uint, page *PageRequest) ([]*Notification, string, error)
 }
 type NotificationService interface {
 	Create(ctx context.Context, notification *Notification) error
 	MarkAsRead(ctx context.Context, notificationID uint) error
-	GetNotifications(ctx context.Context, userID uint) ([]*Notification, error)
+	GetNotifications(ctx context.Context, userID uint, page *PageRequest) ([]*Notification, string, error)
 }
@@ -2,15 +2,27 @@ package models
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// OAuthStateTTL is how long a login started with AuthorizationURL has to come back to the callback
+const OAuthStateTTL = 10 * time.Minute
+
+var (
+	ErrUnknownOAuthProvider = errors.New("unknown oauth provider")
+	ErrInvalidOAuthState    = errors.New("invalid or expired oauth state")
+
+	ErrUnverifiedAccountExists = errors.New("an unverified account already uses this email, log in to it and link the provider from there")
+)
+
 type OAuthProvider struct {
 	ID         uint      `json:"id" gorm:"primarykey"`
-	UserID     uint      `json:"user_id"`
-	Provider   string    `json:"provider"`    // e.g., GitHub, Google
-	ProviderID string    `json:"provider_id"` // The provider-specific user ID
-	Token      string    `json:"token"`       // OAuth access token
+	UserID     uint      `json:"user_id" gorm:"not null;index"`
+	Provider   string    `json:"provider" gorm:"not null;uniqueIndex:idx_oauth_provider_identity"`    // e.g., GitHub, Google
+	ProviderID string    `json:"provider_id" gorm:"not null;uniqueIndex:idx_oauth_provider_identity"` // The provider-specific user ID
+	Email      string    `json:"email"`
+	Token      string    `json:"-"` // OAuth access token
 	CreatedAt  time.Time `json:"created_at" gorm:"default:now()"`
 	UpdatedAt  time.Time `json:"updated_at" gorm:"default:now()"`
 }
@@ -18,4 +30,18 @@ type OAuthProvider struct {
 type OAuthProviderRepository interface {
 	AddProvider(ctx context.Context, provider *OAuthProvider) error
 	GetProvidersByUser(ctx context.Context, userID uint) ([]*OAuthProvider, error)
+	GetByProviderID(ctx context.Context, provider string, providerID string) (*OAuthProvider, error)
+	UpdateToken(ctx context.Context, id uint, token string) error
+	DeleteProvider(ctx context.Context, userID uint, id uint) error
+}
+
+// social login through external OpenID Connect providers
+type OAuthService interface {
+	Providers() []string
+	// AuthorizationURL also returns the state, which the caller binds to the browser that started the flow
+	AuthorizationURL(ctx context.Context, provider string, linkUserID uint) (string, string, error)
+	// HandleCallback only accepts a state whose hash matches stateBinding, as set by the caller
+	HandleCallback(ctx context.Context, provider string, code string, state string, stateBinding string, device *DeviceInfo) (map[string]string, *User, error)
+	GetLinkedProviders(ctx context.Context, userID uint) ([]*OAuthProvider, error)
+	UnlinkProvider(ctx context.Context, userID uint, id uint) error
 }
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var ErrInvalidCursor = errors.New("cursor is invalid")
+
+// Cursor marks where a page ended, by the (created_at, id) of its last row.
+// Clients only ever see it encoded, and hand it back as is to get the next page.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uint
+}
+
+// Paged is a row that can be paged through by its (created_at, id)
+type Paged interface {
+	Cursor() Cursor
+}
+
+func (c Cursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", c.CreatedAt.UnixMicro(), c.ID)))
+}
+
+func DecodeCursor(encoded string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, id, found := strings.Cut(string(raw), ":")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	micros, err := strconv.ParseInt(createdAt, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	rowID, err := strconv.ParseUint(id, 10, strconv.IntSize)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{CreatedAt: time.UnixMicro(micros), ID: uint(rowID)}, nil
+}
+
+// PageRequest asks for up to Limit rows after the After cursor, or the first page when After is nil
+type PageRequest struct {
+	After *Cursor
+	Limit int
+}
+
+// NewPageRequest reads the cursor and limit query params, clamping the limit to MaxPageSize
+func NewPageRequest(cursor string, limit int) (*PageRequest, error) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	page := &PageRequest{Limit: limit}
+	if cursor != "" {
+		after, err := DecodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		page.After = after
+	}
+	return page, nil
+}
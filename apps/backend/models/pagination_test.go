@@ -0,0 +1,125 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{CreatedAt: time.Date(2024, 5, 1, 12, 30, 45, 123456000, time.UTC), ID: 42},
+		{CreatedAt: time.UnixMicro(0), ID: 0},
+		{CreatedAt: time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC), ID: 1},
+		{CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), ID: ^uint(0)},
+	}
+
+	for _, cursor := range tests {
+		decoded, err := DecodeCursor(cursor.Encode())
+		if err != nil {
+			t.Fatalf("DecodeCursor(%v): %v", cursor, err)
+		}
+		if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+			t.Errorf("round trip of %v gave %v", cursor, *decoded)
+		}
+	}
+}
+
+func TestCursorKeepsMicrosecondsOnly(t *testing.T) {
+	// Postgres timestamps stop at microseconds, so finer precision would never match a row
+	cursor := Cursor{CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 123456789, time.UTC), ID: 1}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := cursor.CreatedAt.Truncate(time.Microsecond); !decoded.CreatedAt.Equal(want) {
+		t.Errorf("decoded time %v, want %v", decoded.CreatedAt, want)
+	}
+}
+
+func TestCursorIsURLSafe(t *testing.T) {
+	encoded := Cursor{CreatedAt: time.Now(), ID: 99}.Encode()
+	for _, c := range encoded {
+		if c == '+' || c == '/' || c == '=' {
+			t.Fatalf("cursor %q has characters that need escaping in a query string", encoded)
+		}
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("12:3"))},
+		{"empty payload", encode("")},
+		{"missing separator", encode("12345")},
+		{"missing id", encode("12345:")},
+		{"missing time", encode(":42")},
+		{"negative id", encode("12345:-1")},
+		{"non-numeric time", encode("yesterday:42")},
+		{"trailing garbage", encode("12345:42x")},
+		{"extra field", encode("12345:42:7")},
+		{"id overflow", encode("12345:99999999999999999999999")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if cursor, err := DecodeCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor = (%v, %v), want ErrInvalidCursor", cursor, err)
+			}
+		})
+	}
+}
+
+func TestNewPageRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		want  int
+	}{
+		{"default when unset", 0, DefaultPageSize},
+		{"default when negative", -5, DefaultPageSize},
+		{"as asked", 50, 50},
+		{"at the cap", MaxPageSize, MaxPageSize},
+		{"clamped", MaxPageSize + 1, MaxPageSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := NewPageRequest("", tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Limit != tt.want {
+				t.Errorf("Limit = %d, want %d", page.Limit, tt.want)
+			}
+			if page.After != nil {
+				t.Errorf("After = %v, want nil for the first page", page.After)
+			}
+		})
+	}
+}
+
+func TestNewPageRequestCursor(t *testing.T) {
+	cursor := Cursor{CreatedAt: time.UnixMicro(1714566645123456), ID: 7}
+
+	page, err := NewPageRequest(cursor.Encode(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.After == nil || !page.After.CreatedAt.Equal(cursor.CreatedAt) || page.After.ID != cursor.ID {
+		t.Errorf("After = %v, want %v", page.After, cursor)
+	}
+
+	if _, err := NewPageRequest("not a cursor", 10); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("NewPageRequest with a bad cursor error = %v, want ErrInvalidCursor", err)
+	}
+}
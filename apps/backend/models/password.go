@@ -0,0 +1,18 @@
+package models
+
+import "errors"
+
+var ErrWeakPassword = errors.New("password does not meet the password policy")
+
+// PasswordHasher hashes new passwords with the preferred algorithm and verifies hashes from any supported one
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password string, hash string) (bool, error)
+	// NeedsRehash reports whether hash was made with another algorithm or weaker parameters than Hash uses now
+	NeedsRehash(hash string) bool
+}
+
+type PasswordPolicy interface {
+	// Validate returns an error wrapping ErrWeakPassword that explains what is wrong
+	Validate(password string, username string, email string) error
+}
@@ -6,16 +6,22 @@ import (
 )
 
 type PublicMessage struct {
-	ID        uint      `json:"id" gorm:"primarykey"`
-	UserID    uint      `json:"user_id" gorm:"not null"` // The user who posted the message
-	User      User      `json:"user" gorm:"foreignKey:UserID"`
-	Content   string    `json:"content" gorm:"text;not null"`
-	MediaURL  string    `json:"media_url" gorm:"text"` // Optional media (image/video)
-	Likes     []*User   `json:"likes" gorm:"many2many:public_message_likes"`
-	Comments  []Comment `json:"comments" gorm:"foreignKey:PublicMessageID"`
-	Shares    int       `json:"shares" gorm:"default:0"`
-	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID            uint      `json:"id" gorm:"primarykey"`
+	UserID        uint      `json:"user_id" gorm:"not null"` // The user who posted the message
+	User          User      `json:"user" gorm:"foreignKey:UserID"`
+	BoringSpaceID *uint     `json:"boringspace_id,omitempty" gorm:"index"` // Set when posted in a space, nil for the global feed
+	Content       string    `json:"content" gorm:"text;not null"`
+	MediaURL      string    `json:"media_url" gorm:"text"` // Optional media (image/video)
+	Likes         []*User   `json:"likes" gorm:"many2many:public_message_likes"`
+	Comments      []Comment `json:"comments,omitempty" gorm:"foreignKey:PublicMessageID"` // Not loaded, page through /:id/comments instead
+	CommentCount  int64     `json:"comment_count" gorm:"->;-:migration"`
+	Shares        int       `json:"shares" gorm:"default:0"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (m *PublicMessage) Cursor() Cursor {
+	return Cursor{CreatedAt: m.CreatedAt, ID: m.ID}
 }
 
 type Comment struct {
@@ -29,24 +35,30 @@ type Comment struct {
 	UpdatedAt       time.Time     `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
+func (c *Comment) Cursor() Cursor {
+	return Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+}
+
 type PublicMessageRepository interface {
 	CreatePublicMessage(ctx context.Context, message *PublicMessage) error
 	GetPublicMessageByID(ctx context.Context, messageID uint) (*PublicMessage, error)
-	GetPublicMessages(ctx context.Context, limit, offset int) ([]*PublicMessage, error)
+	GetPublicMessages(ctx context.Context, page *PageRequest) ([]*PublicMessage, string, error)
+	GetSpaceMessages(ctx context.Context, spaceID uint, page *PageRequest) ([]*PublicMessage, string, error)
 	DeletePublicMessage(ctx context.Context, messageID uint) error
 	LikePublicMessage(ctx context.Context, messageID uint, userID uint) error
 	UnlikePublicMessage(ctx context.Context, messageID uint, userID uint) error
 	CreateComment(ctx context.Context, comment *Comment) error
-	GetCommentsByMessageID(ctx context.Context, messageID uint) ([]*Comment, error)
+	GetCommentsByMessageID(ctx context.Context, messageID uint, page *PageRequest) ([]*Comment, string, error)
 }
 
 type PublicMessageService interface {
 	CreatePublicMessage(ctx context.Context, message *PublicMessage) error
 	GetPublicMessageByID(ctx context.Context, messageID uint) (*PublicMessage, error)
-	GetPublicMessages(ctx context.Context, limit, offset int) ([]*PublicMessage, error)
+	GetPublicMessages(ctx context.Context, page *PageRequest) ([]*PublicMessage, string, error)
+	GetSpaceMessages(ctx context.Context, spaceID uint, page *PageRequest) ([]*PublicMessage, string, error)
 	DeletePublicMessage(ctx context.Context, messageID uint) error
 	LikePublicMessage(ctx context.Context, messageID uint, userID uint) error
 	UnlikePublicMessage(ctx context.Context, messageID uint, userID uint) error
 	CreateComment(ctx context.Context, comment *Comment) error
-	GetCommentsByMessageID(ctx context.Context, messageID uint) ([]*Comment, error)
+	GetCommentsByMessageID(ctx context.Context, messageID uint, page *PageRequest) ([]*Comment, string, error)
 }
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"not null;index"`
+	CodeHash  string     `gorm:"not null;unique"`
+	UsedAt    *time.Time // Recovery codes are single use
+	CreatedAt time.Time
+}
+
+type RecoveryCodeRepository interface {
+	ReplaceCodes(ctx context.Context, userID uint, codeHashes []string) error
+	ConsumeCode(ctx context.Context, userID uint, codeHash string) (bool, error)
+	DeleteCodes(ctx context.Context, userID uint) error
+}
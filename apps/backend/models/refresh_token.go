@@ -2,21 +2,54 @@ package models
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected, all sessions in this family have been revoked")
+
 type RefreshToken struct {
-	ID        uint      `gorm:"primaryKey"`
-	UserID    uint      `gorm:"not null"`
-	Token     string    `gorm:"not null;unique"`
-	ExpiresAt time.Time `gorm:"not null"`
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"not null;index"`
+	TokenHash string     `gorm:"not null;unique"` // SHA-256 of the refresh JWT, the raw token is never stored
+	FamilyID  string     `gorm:"not null;index"`  // Shared by every token descending from the same login
+	ExpiresAt time.Time  `gorm:"not null"`
+	RotatedAt *time.Time // Set once the token has been exchanged for a new one
+	RevokedAt *time.Time
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// Device metadata, refreshed on every rotation
+	UserAgent        string
+	IPAddress        string
+	SessionStartedAt time.Time `gorm:"not null;default:now()"`
+	LastUsedAt       time.Time `gorm:"not null;default:now()"`
+}
+
+// describes the client a session was started or refreshed from
+type DeviceInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// an active login, i.e. the live head of a refresh token family
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
 }
 
 type RefreshTokenRepository interface {
 	Create(ctx context.Context, refreshToken *RefreshToken) error
-	FindByToken(ctx context.Context, token string) (*RefreshToken, error)
-	Delete(ctx context.Context, token string) error
+	FindByHash(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	Rotate(ctx context.Context, current *RefreshToken, next *RefreshToken) error
+	Delete(ctx context.Context, tokenHash string) error
+	RevokeFamily(ctx context.Context, familyID string) error
+	ListActive(ctx context.Context, userID uint) ([]*RefreshToken, error)
+	RevokeUserFamilies(ctx context.Context, userID uint, familyIDs []string) error
 	InvalidateOldTokens(ctx context.Context, userID uint) error
 }
@@ -0,0 +1,8 @@
+package models
+
+import "context"
+
+// delivers text messages such as phone verification codes
+type SMSSender interface {
+	Send(ctx context.Context, to string, body string) error
+}
@@ -26,6 +26,7 @@ type User struct {
 	Email            string              `json:"email" gorm:"text;not null;unique"` // email will be like montekkundan@bored.rocks
 	PasswordHash     string              `json:"-" gorm:"text;not null"`            // Do not expose password hash in JSON
 	TokenVersion     int                 `json:"token_version" gorm:"default:1"`
+	ImpersonatedBy   *uint               `json:"impersonated_by,omitempty" gorm:"-"` // Set on /auth/me when an admin is acting as this user
 	Bio              string              `json:"bio" gorm:"text"`
 	Interests        []string            `json:"interests" gorm:"type:text[]"`
 	Latitude         float64             `json:"latitude" gorm:"numeric(9,6)"`
@@ -41,6 +42,8 @@ type User struct {
 	PhoneNumber      string              `json:"phone_number" gorm:"text;unique"` // Phone number for 2FA
 	PhoneVerified    bool                `json:"phone_verified" gorm:"default:false"`
 	TwoFactorEnabled bool                `json:"two_factor_enabled" gorm:"default:false"` // 2FA enabled
+	TwoFactorSecret  string              `json:"-" gorm:"text"`                           // TOTP secret, pending until confirmed
+	TwoFactorStep    int64               `json:"-" gorm:"default:0"`                      // Last accepted TOTP step, blocks code replay
 	RewardPoints     int                 `json:"reward_points" gorm:"default:0"`
 	Followers        []User              `gorm:"many2many:user_followers"`               // Followers relationship
 	Following        []User              `gorm:"many2many:user_following"`               // Following relationship
@@ -62,8 +65,9 @@ type UserService interface {
 	UpdateUser(ctx context.Context, user *User) error
 	DeleteUserByID(ctx context.Context, userID uint) error
 	DeactivateUser(ctx context.Context, userID uint) error
+	IncrementTokenVersion(ctx context.Context, userID uint) error
 	GetUserBoringSpaces(ctx context.Context, userID uint) ([]*BoringSpaceMember, error)
-	GetAllPublicMessages(ctx context.Context, limit, offset int) ([]*PublicMessage, error)
+	GetAllPublicMessages(ctx context.Context, page *PageRequest) ([]*PublicMessage, string, error)
 }
 
 type UserRepository interface {
@@ -74,8 +78,9 @@ type UserRepository interface {
 	UpdateUser(ctx context.Context, user *User) error
 	DeleteUserByID(ctx context.Context, userID uint) error
 	DeactivateUser(ctx context.Context, userID uint) error
+	IncrementTokenVersion(ctx context.Context, userID uint) error
 	GetUserBoringSpaces(ctx context.Context, userID uint) ([]*BoringSpaceMember, error)
-	GetAllPublicMessages(ctx context.Context, limit, offset int) ([]*PublicMessage, error)
+	GetAllPublicMessages(ctx context.Context, page *PageRequest) ([]*PublicMessage, string, error)
 }
 
 // AfterCreate hook to assign the admin role to the first user in the database
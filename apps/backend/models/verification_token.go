@@ -0,0 +1,29 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+type VerificationPurpose string
+
+const (
+	EmailVerificationPurpose VerificationPurpose = "email_verification"
+	PasswordResetPurpose     VerificationPurpose = "password_reset"
+)
+
+type VerificationToken struct {
+	ID        uint                `gorm:"primaryKey"`
+	UserID    uint                `gorm:"not null;index"`
+	Purpose   VerificationPurpose `gorm:"type:text;not null"`
+	TokenHash string              `gorm:"not null;unique"` // Hash of the signed token's nonce
+	ExpiresAt time.Time           `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type VerificationTokenRepository interface {
+	Create(ctx context.Context, token *VerificationToken) error
+	Consume(ctx context.Context, purpose VerificationPurpose, tokenHash string) (*VerificationToken, error)
+	DeleteForUser(ctx context.Context, userID uint, purpose VerificationPurpose) error
+}
@@ -0,0 +1,283 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID = "bored-test"
+	testKID      = "idp-key"
+)
+
+// testIdP is a minimal OpenID provider serving discovery, JWKS and a PKCE-checking token endpoint
+type testIdP struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]issuedCode
+}
+
+type issuedCode struct {
+	challenge string
+	idToken   string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &testIdP{t: t, key: key, codes: map[string]issuedCode{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discoveryDocument{
+			Issuer:                idp.URL,
+			AuthorizationEndpoint: idp.URL + "/authorize",
+			TokenEndpoint:         idp.URL + "/token",
+			JWKSURI:               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jsonWebKeySet{Keys: []jsonWebKey{{
+			Kty: "RSA",
+			Kid: testKID,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+func (idp *testIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "authorization_code" || r.Form.Get("client_id") != testClientID {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+
+	idp.mu.Lock()
+	issued, ok := idp.codes[r.Form.Get("code")]
+	delete(idp.codes, r.Form.Get("code"))
+	idp.mu.Unlock()
+
+	if !ok || CodeChallenge(r.Form.Get("code_verifier")) != issued.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(TokenResponse{AccessToken: "access", TokenType: "Bearer", IDToken: issued.idToken, ExpiresIn: 3600})
+}
+
+// authorize plays the user approving the request at the IdP and returns the code sent back to the callback
+func (idp *testIdP) authorize(authURL, idToken string) string {
+	idp.t.Helper()
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	if method := parsed.Query().Get("code_challenge_method"); method != "S256" {
+		idp.t.Fatalf("code_challenge_method = %q, want S256", method)
+	}
+
+	code := "code-" + parsed.Query().Get("state")
+	idp.mu.Lock()
+	idp.codes[code] = issuedCode{challenge: parsed.Query().Get("code_challenge"), idToken: idToken}
+	idp.mu.Unlock()
+	return code
+}
+
+func (idp *testIdP) claims(nonce string) *IDTokenClaims {
+	now := time.Now()
+	return &IDTokenClaims{
+		Subject:       "user-1",
+		Email:         "jane@example.com",
+		EmailVerified: true,
+		Nonce:         nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    idp.URL,
+			Audience:  jwt.ClaimStrings{testClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	}
+}
+
+func (idp *testIdP) sign(claims *IDTokenClaims, key *rsa.PrivateKey) string {
+	idp.t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	return signed
+}
+
+func (idp *testIdP) provider() *Provider {
+	return NewProvider(ProviderConfig{
+		Name:        "test",
+		Issuer:      idp.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost/callback",
+		Scopes:      []string{"openid", "email"},
+	})
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := newTestIdP(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   func() string
+		wantErr bool
+	}{
+		{"valid", func() string {
+			return idp.sign(idp.claims("nonce"), idp.key)
+		}, false},
+		{"within the leeway", func() string {
+			claims := idp.claims("nonce")
+			claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-30 * time.Second))
+			return idp.sign(claims, idp.key)
+		}, false},
+		{"bad signature", func() string {
+			return idp.sign(idp.claims("nonce"), otherKey)
+		}, true},
+		{"wrong audience", func() string {
+			claims := idp.claims("nonce")
+			claims.Audience = jwt.ClaimStrings{"someone-else"}
+			return idp.sign(claims, idp.key)
+		}, true},
+		{"wrong issuer", func() string {
+			claims := idp.claims("nonce")
+			claims.Issuer = "https://evil.example.com"
+			return idp.sign(claims, idp.key)
+		}, true},
+		{"nonce mismatch", func() string {
+			return idp.sign(idp.claims("other-nonce"), idp.key)
+		}, true},
+		{"expired", func() string {
+			claims := idp.claims("nonce")
+			claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-5 * time.Minute))
+			return idp.sign(claims, idp.key)
+		}, true},
+		{"no expiry", func() string {
+			claims := idp.claims("nonce")
+			claims.ExpiresAt = nil
+			return idp.sign(claims, idp.key)
+		}, true},
+		{"missing subject", func() string {
+			claims := idp.claims("nonce")
+			claims.Subject = ""
+			return idp.sign(claims, idp.key)
+		}, true},
+		{"HMAC with the public key", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.claims("nonce"))
+			token.Header["kid"] = testKID
+			signed, err := token.SignedString(idp.key.N.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signed
+		}, true},
+		{"unsigned", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodNone, idp.claims("nonce"))
+			signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signed
+		}, true},
+	}
+
+	provider := idp.provider()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := provider.VerifyIDToken(context.Background(), tt.token(), "nonce")
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Errorf("VerifyIDToken error = %v, want ErrInvalidIDToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyIDToken: %v", err)
+			}
+			if claims.Subject != "user-1" || claims.Email != "jane@example.com" || !claims.EmailVerified {
+				t.Errorf("VerifyIDToken = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestCodeFlowWithPKCE(t *testing.T) {
+	idp := newTestIdP(t)
+	provider := idp.provider()
+	ctx := context.Background()
+
+	verifier, err := RandomString(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := idp.authorize(authURL, idp.sign(idp.claims("nonce"), idp.key))
+	token, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if _, err := provider.VerifyIDToken(ctx, token.IDToken, "nonce"); err != nil {
+		t.Errorf("VerifyIDToken: %v", err)
+	}
+
+	// A code intercepted on its way back can't be redeemed without the verifier
+	code = idp.authorize(authURL, idp.sign(idp.claims("nonce"), idp.key))
+	if _, err := provider.Exchange(ctx, code, "wrong-verifier"); err == nil {
+		t.Error("Exchange with the wrong verifier succeeded")
+	}
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// RFC 7636, appendix B
+	if got := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("CodeChallenge = %q", got)
+	}
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	idp := newTestIdP(t)
+	provider := NewProvider(ProviderConfig{Name: "test", Issuer: idp.URL + "/", ClientID: testClientID, RedirectURL: "http://localhost/callback"})
+
+	if _, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier"); err == nil {
+		t.Error("AuthCodeURL accepted a discovery document for another issuer")
+	}
+}
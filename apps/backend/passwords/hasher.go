@@ -0,0 +1,67 @@
+package passwords
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/montekkundan/bored/backend/config"
+	"github.com/montekkundan/bored/backend/models"
+)
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// algorithm is one supported hashing scheme, recognised by the prefix of its encoded hashes
+type algorithm interface {
+	hash(password string) (string, error)
+	verify(password string, hash string) (bool, error)
+	outdated(hash string) bool
+	recognises(hash string) bool
+}
+
+// Hasher hashes with the configured algorithm and keeps verifying the others so old hashes
+// keep working until the user next logs in and they get upgraded
+type Hasher struct {
+	preferred  algorithm
+	algorithms []algorithm
+}
+
+// NewHasher picks the algorithm configured through PASSWORD_HASHER
+func NewHasher(config *config.EnvConfig) (models.PasswordHasher, error) {
+	argon := &argon2idAlgorithm{
+		memory:      uint32(config.Argon2Memory),
+		iterations:  uint32(config.Argon2Iterations),
+		parallelism: uint8(config.Argon2Parallelism),
+	}
+	bcrypt := &bcryptAlgorithm{cost: config.BcryptCost}
+
+	hasher := &Hasher{algorithms: []algorithm{argon, bcrypt}}
+
+	switch strings.ToLower(config.PasswordHasher) {
+	case "argon2id", "":
+		hasher.preferred = argon
+	case "bcrypt":
+		hasher.preferred = bcrypt
+	default:
+		return nil, fmt.Errorf("unknown password hasher %q", config.PasswordHasher)
+	}
+
+	return hasher, nil
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.preferred.hash(password)
+}
+
+func (h *Hasher) Verify(password string, hash string) (bool, error) {
+	for _, algo := range h.algorithms {
+		if algo.recognises(hash) {
+			return algo.verify(password, hash)
+		}
+	}
+	return false, ErrUnknownHashFormat
+}
+
+func (h *Hasher) NeedsRehash(hash string) bool {
+	return !h.preferred.recognises(hash) || h.preferred.outdated(hash)
+}
@@ -0,0 +1,200 @@
+package passwords
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/montekkundan/bored/backend/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testConfig keeps the work factors low so the tests stay fast
+func testConfig(hasher string) *config.EnvConfig {
+	return &config.EnvConfig{
+		PasswordHasher:    hasher,
+		Argon2Memory:      1024,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+		BcryptCost:        bcrypt.MinCost,
+	}
+}
+
+func newTestHasher(t *testing.T, hasher string) *Hasher {
+	t.Helper()
+	h, err := NewHasher(testConfig(hasher))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h.(*Hasher)
+}
+
+func TestNewHasher(t *testing.T) {
+	tests := []struct {
+		name    string
+		hasher  string
+		wantErr bool
+	}{
+		{"default", "", false},
+		{"argon2id", "argon2id", false},
+		{"case insensitive", "Argon2ID", false},
+		{"bcrypt", "bcrypt", false},
+		{"unknown", "md5", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHasher(testConfig(tt.hasher)); (err != nil) != tt.wantErr {
+				t.Errorf("NewHasher(%q) error = %v, wantErr %v", tt.hasher, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHashAndVerify(t *testing.T) {
+	for _, name := range []string{"argon2id", "bcrypt"} {
+		t.Run(name, func(t *testing.T) {
+			h := newTestHasher(t, name)
+
+			hash, err := h.Hash("correct horse battery staple")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ok, err := h.Verify("correct horse battery staple", hash); err != nil || !ok {
+				t.Errorf("Verify(correct password) = (%v, %v), want (true, nil)", ok, err)
+			}
+			if ok, err := h.Verify("wrong password", hash); err != nil || ok {
+				t.Errorf("Verify(wrong password) = (%v, %v), want (false, nil)", ok, err)
+			}
+			if h.NeedsRehash(hash) {
+				t.Error("a fresh hash needs a rehash")
+			}
+		})
+	}
+}
+
+func TestArgon2idHashFormat(t *testing.T) {
+	hash, err := newTestHasher(t, "argon2id").Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("hash %q is not in the expected PHC format", hash)
+	}
+
+	other, err := newTestHasher(t, "argon2id").Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == other {
+		t.Error("hashing the same password twice gave the same hash, the salt is not random")
+	}
+}
+
+func TestVerifyLegacyBcryptHashes(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHasher(t, "argon2id")
+	if ok, err := h.Verify("password", string(legacy)); err != nil || !ok {
+		t.Errorf("Verify(bcrypt hash) = (%v, %v), want (true, nil)", ok, err)
+	}
+	if !h.NeedsRehash(string(legacy)) {
+		t.Error("a bcrypt hash does not need a rehash while argon2id is preferred")
+	}
+}
+
+func TestVerifyRejectsMalformedHashes(t *testing.T) {
+	h := newTestHasher(t, "argon2id")
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"unknown format", "plaintext"},
+		{"empty", ""},
+		{"argon2i", "$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5"},
+		{"missing key", "$argon2id$v=19$m=1024,t=1,p=1$c2FsdA"},
+		{"unsupported version", "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5"},
+		{"bad parameters", "$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5"},
+		{"bad salt", "$argon2id$v=19$m=1024,t=1,p=1$!!!$a2V5"},
+		{"bad key", "$argon2id$v=19$m=1024,t=1,p=1$c2FsdA$!!!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok, err := h.Verify("password", tt.hash); err == nil || ok {
+				t.Errorf("Verify = (%v, %v), want an error", ok, err)
+			}
+		})
+	}
+
+	if _, err := h.Verify("password", "plaintext"); !errors.Is(err, ErrUnknownHashFormat) {
+		t.Errorf("Verify(unknown format) error = %v, want ErrUnknownHashFormat", err)
+	}
+}
+
+func TestDecodeArgon2(t *testing.T) {
+	params, err := decodeArgon2("$argon2id$v=19$m=65536,t=3,p=2$c2FsdHNhbHRzYWx0c2FsdA$a2V5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if params.memory != 65536 || params.iterations != 3 || params.parallelism != 2 {
+		t.Errorf("decoded m=%d,t=%d,p=%d, want m=65536,t=3,p=2", params.memory, params.iterations, params.parallelism)
+	}
+	if string(params.salt) != "saltsaltsaltsalt" || string(params.key) != "key" {
+		t.Errorf("decoded salt %q and key %q", params.salt, params.key)
+	}
+}
+
+func TestArgon2idNeedsRehash(t *testing.T) {
+	h := newTestHasher(t, "argon2id")
+
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{"same parameters", "$argon2id$v=19$m=1024,t=1,p=1$c2FsdA$a2V5", false},
+		{"stronger parameters", "$argon2id$v=19$m=2048,t=2,p=2$c2FsdA$a2V5", false},
+		{"less memory", "$argon2id$v=19$m=512,t=1,p=1$c2FsdA$a2V5", true},
+		{"fewer iterations", "$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$a2V5", true},
+		{"less parallelism", "$argon2id$v=19$m=1024,t=1,p=0$c2FsdA$a2V5", true},
+		{"malformed", "$argon2id$garbage", true},
+		{"bcrypt", "$2a$04$abcdefghijklmnopqrstuu", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBcryptNeedsRehash(t *testing.T) {
+	h := newTestHasher(t, "bcrypt")
+	h.preferred = &bcryptAlgorithm{cost: bcrypt.MinCost + 1}
+
+	weak, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.NeedsRehash(string(weak)) {
+		t.Error("a bcrypt hash below the configured cost does not need a rehash")
+	}
+
+	argon, err := newTestHasher(t, "argon2id").Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.NeedsRehash(argon) {
+		t.Error("an argon2id hash does not need a rehash while bcrypt is preferred")
+	}
+}
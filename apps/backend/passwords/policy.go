@@ -0,0 +1,101 @@
+package passwords
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/montekkundan/bored/backend/config"
+	"github.com/montekkundan/bored/backend/models"
+)
+
+//go:embed common-passwords.txt
+var commonPasswordsFile string
+
+// minIdentifierLength keeps short usernames like "jo" from ruling out half of all passwords
+const minIdentifierLength = 3
+
+type Policy struct {
+	minLength int
+	maxLength int
+	common    map[string]struct{}
+}
+
+func NewPolicy(config *config.EnvConfig) models.PasswordPolicy {
+	common := map[string]struct{}{}
+
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswordsFile))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		common[strings.ToLower(line)] = struct{}{}
+	}
+
+	return &Policy{
+		minLength: config.PasswordMinLength,
+		maxLength: config.PasswordMaxLength,
+		common:    common,
+	}
+}
+
+func (p *Policy) Validate(password string, username string, email string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		return fmt.Errorf("%w: it must be at least %d characters long", models.ErrWeakPassword, p.minLength)
+	}
+	if length > p.maxLength {
+		return fmt.Errorf("%w: it must be at most %d characters long", models.ErrWeakPassword, p.maxLength)
+	}
+
+	normalized := strings.ToLower(password)
+	if _, ok := p.common[normalized]; ok {
+		return fmt.Errorf("%w: it is too common", models.ErrWeakPassword)
+	}
+
+	localPart, _, _ := strings.Cut(email, "@")
+	for _, identifier := range []string{username, localPart, email} {
+		if tooSimilar(normalized, strings.ToLower(identifier)) {
+			return fmt.Errorf("%w: it is too similar to your username or email", models.ErrWeakPassword)
+		}
+	}
+
+	return nil
+}
+
+// tooSimilar catches passwords that contain the identifier or are a few edits away from it
+func tooSimilar(password string, identifier string) bool {
+	if utf8.RuneCountInString(identifier) < minIdentifierLength {
+		return false
+	}
+	if strings.Contains(password, identifier) || strings.Contains(identifier, password) {
+		return true
+	}
+	return levenshtein(password, identifier) <= utf8.RuneCountInString(password)/3
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
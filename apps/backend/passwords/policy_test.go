@@ -0,0 +1,64 @@
+package passwords
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/montekkundan/bored/backend/config"
+	"github.com/montekkundan/bored/backend/models"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	policy := NewPolicy(&config.EnvConfig{PasswordMinLength: 10, PasswordMaxLength: 20})
+
+	tests := []struct {
+		name     string
+		password string
+		username string
+		email    string
+		wantErr  bool
+	}{
+		{"acceptable", "plum-orbit-canvas", "jane", "jane@example.com", false},
+		{"too short", "short1!", "jane", "jane@example.com", true},
+		{"too long", "this-password-is-far-too-long", "jane", "jane@example.com", true},
+		{"length counts runes", "ééééééééééé", "jane", "jane@example.com", false},
+		{"common", "password123", "jane", "jane@example.com", true},
+		{"common in another case", "PASSWORD123", "jane", "jane@example.com", true},
+		{"contains username", "janedoe-2024!", "janedoe", "jd@example.com", true},
+		{"contains email local part", "xx-montgomery-xx", "jane", "montgomery@example.com", true},
+		{"close to username", "montgomeri42", "montgomery", "jd@example.com", true},
+		{"short usernames are ignored", "jo-plum-orbit", "jo", "jo@ex.io", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, tt.username, tt.email)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate(%q) error = %v, wantErr %v", tt.password, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, models.ErrWeakPassword) {
+				t.Errorf("Validate(%q) error = %v, want ErrWeakPassword", tt.password, err)
+			}
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"héllo", "hello", 1},
+	}
+
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
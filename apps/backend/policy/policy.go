@@ -0,0 +1,197 @@
+package policy
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/montekkundan/bored/backend/models"
+	"gorm.io/gorm"
+)
+
+var ErrForbidden = errors.New("permission denied")
+
+// Authorizer answers "may the signed in user do this" for routes and handlers.
+// Middlewares leave what they loaded in Locals("user") and Locals("spaceMember") for the handler.
+type Authorizer struct {
+	users  models.UserService
+	spaces models.BoringSpaceService
+}
+
+func NewAuthorizer(users models.UserService, spaces models.BoringSpaceService) *Authorizer {
+	return &Authorizer{users: users, spaces: spaces}
+}
+
+// RequireRole lets the request through if the user has any of roles
+func (a *Authorizer) RequireRole(roles ...models.UserRole) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		user, err := a.CurrentUser(ctx)
+		if err != nil {
+			return Deny(ctx, err)
+		}
+
+		for _, role := range roles {
+			if user.HasRole(role) {
+				return ctx.Next()
+			}
+		}
+		return Deny(ctx, ErrForbidden)
+	}
+}
+
+// RequireSpaceRole lets members of the space in the :id route param through if their role is at least min
+func (a *Authorizer) RequireSpaceRole(min models.BoringSpaceRole) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		member, err := a.routeSpaceMember(ctx)
+		if err != nil {
+			return Deny(ctx, err)
+		}
+
+		if !member.Role.AtLeast(min) {
+			return Deny(ctx, ErrForbidden)
+		}
+		return ctx.Next()
+	}
+}
+
+// RequireSpacePermission lets members of the space in the :id route param through if their role grants perm
+func (a *Authorizer) RequireSpacePermission(perm models.BoringSpacePermission) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		member, err := a.routeSpaceMember(ctx)
+		if err != nil {
+			return Deny(ctx, err)
+		}
+
+		allowed, err := a.spaces.HasPermission(ctx.Context(), member, perm)
+		if err != nil {
+			return Deny(ctx, err)
+		}
+		if !allowed {
+			return Deny(ctx, ErrForbidden)
+		}
+		return ctx.Next()
+	}
+}
+
+// RequireSpaceVisible hides private spaces from non-members. They get the same 404 as for a space
+// that doesn't exist, so the existence of a private space doesn't leak.
+func (a *Authorizer) RequireSpaceVisible() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		spaceID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "Invalid BoringSpace ID",
+			})
+		}
+
+		visible, err := a.SpaceVisible(ctx, uint(spaceID))
+		if err != nil {
+			return Deny(ctx, err)
+		}
+		if !visible {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "BoringSpace not found",
+			})
+		}
+		return ctx.Next()
+	}
+}
+
+// SpaceVisible is RequireSpaceVisible for handlers that only learn the space from what they loaded
+func (a *Authorizer) SpaceVisible(ctx *fiber.Ctx, spaceID uint) (bool, error) {
+	return a.spaces.CanView(ctx.Context(), spaceID, ctx.Locals("userId").(uint))
+}
+
+// SpaceCan is RequireSpacePermission for handlers that only learn the space from the request body
+func (a *Authorizer) SpaceCan(ctx *fiber.Ctx, spaceID uint, perm models.BoringSpacePermission) error {
+	member, err := a.spaceMember(ctx, spaceID)
+	if err != nil {
+		return err
+	}
+
+	allowed, err := a.spaces.HasPermission(ctx.Context(), member, perm)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (a *Authorizer) routeSpaceMember(ctx *fiber.Ctx) (*models.BoringSpaceMember, error) {
+	spaceID, err := strconv.ParseUint(ctx.Params("id"), 10, 32)
+	if err != nil {
+		// Not a space anyone could be a member of
+		return nil, ErrForbidden
+	}
+	return a.spaceMember(ctx, uint(spaceID))
+}
+
+// spaceMember loads the caller's membership, leaving it in Locals("spaceMember") for the handler
+func (a *Authorizer) spaceMember(ctx *fiber.Ctx, spaceID uint) (*models.BoringSpaceMember, error) {
+	if member, ok := ctx.Locals("spaceMember").(*models.BoringSpaceMember); ok && member.BoringSpaceID == spaceID {
+		return member, nil
+	}
+
+	member, err := a.spaces.GetMember(ctx.Context(), spaceID, ctx.Locals("userId").(uint))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrForbidden
+		}
+		return nil, err
+	}
+
+	ctx.Locals("spaceMember", member)
+	return member, nil
+}
+
+// OwnerOrAdmin allows the owner of a resource, or a site admin, to act on it
+func (a *Authorizer) OwnerOrAdmin(ctx *fiber.Ctx, ownerID uint) error {
+	if ctx.Locals("userId").(uint) == ownerID {
+		return nil
+	}
+
+	user, err := a.CurrentUser(ctx)
+	if err != nil {
+		return err
+	}
+	if !user.HasRole(models.Admin) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// CurrentUser loads the signed in user once per request
+func (a *Authorizer) CurrentUser(ctx *fiber.Ctx) (*models.User, error) {
+	if user, ok := ctx.Locals("user").(*models.User); ok {
+		return user, nil
+	}
+
+	user, err := a.users.GetUserByID(ctx.Context(), ctx.Locals("userId").(uint))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrForbidden
+		}
+		return nil, err
+	}
+
+	ctx.Locals("user", user)
+	return user, nil
+}
+
+// Deny is the one response every authorization failure gets
+func Deny(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, ErrForbidden) {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Permission denied",
+		})
+	}
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Could not check permissions",
+	})
+}
@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/montekkundan/bored/backend/models"
+	"gorm.io/gorm"
+)
+
+// AuditLogRepository has no update or delete on purpose, the table is append-only
+type AuditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) *AuditLogRepository {
+	return &AuditLogRepository{db: db}
+}
+
+func (r *AuditLogRepository) Create(ctx context.Context, entry *models.AuditLog) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+func (r *AuditLogRepository) Find(ctx context.Context, filter *models.AuditLogFilter) ([]*models.AuditLog, error) {
+	query := r.db.WithContext(ctx).Model(&models.AuditLog{})
+
+	if filter.UserID != nil {
+		query = query.Where("actor_id = ? OR target_id = ?", *filter.UserID, *filter.UserID)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	var entries []*models.AuditLog
+	err := query.Order("created_at desc, id desc").Limit(filter.Limit).Offset(filter.Offset).Find(&entries).Error
+	return entries, err
+}
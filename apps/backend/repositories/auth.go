@@ -48,21 +48,36 @@ func (r *AuthRepository) VerifyEmail(ctx context.Context, userID uint) error {
 	return r.db.Save(user).Error
 }
 
-func (r *AuthRepository) VerifyPhoneNumber(ctx context.Context, userID uint, code string) error {
-	user := &models.User{}
-	if err := r.db.Model(user).Where("id = ?", userID).First(user).Error; err != nil {
-		return err
-	}
-
-	// Logic to verify the phone number with the given code
-	// Here, you could compare the 'code' with a code sent to the user's phone
-	// For demonstration purposes, we'll assume the code is "123456"
-	if code != "123456" {
-		return gorm.ErrRecordNotFound // Or a custom error indicating the code is invalid
-	}
+// MarkPhoneVerified saves phoneNumber as the user's verified number.
+// It fails with models.ErrPhoneNumberTaken if another account already has the number.
+func (r *AuthRepository) MarkPhoneVerified(ctx context.Context, userID uint, phoneNumber string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var taken int64
+		if err := tx.Model(&models.User{}).Where("phone_number = ? AND id <> ?", phoneNumber, userID).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return models.ErrPhoneNumberTaken
+		}
+
+		res := tx.Model(&models.User{}).
+			Where("id = ?", userID).
+			Updates(map[string]interface{}{"phone_number": phoneNumber, "phone_verified": true})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
 
-	user.PhoneVerified = true
-	return r.db.Save(user).Error
+func (r *AuthRepository) UpdatePassword(ctx context.Context, userID uint, passwordHash string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("password_hash", passwordHash).Error
 }
 
 func NewAuthRepository(db *gorm.DB) models.AuthRepository {
@@ -2,9 +2,12 @@ package repositories
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/montekkundan/bored/backend/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BoringSpaceRepository struct {
@@ -18,7 +21,88 @@ func NewBoringSpaceRepository(db *gorm.DB) models.BoringSpaceRepository {
 }
 
 func (r *BoringSpaceRepository) CreateBoringSpace(ctx context.Context, space *models.BoringSpace) error {
-	return r.db.WithContext(ctx).Create(space).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(space).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.BoringSpaceMember{
+			BoringSpaceID: space.ID,
+			UserID:        space.CreatorID,
+			Role:          models.BSAdmin,
+		}).Error
+	})
+}
+
+func (r *BoringSpaceRepository) UpdateBoringSpace(ctx context.Context, space *models.BoringSpace) error {
+	return r.db.WithContext(ctx).
+		Model(space).
+		Select("name", "description", "visibility", "updated_at").
+		Updates(space).Error
+}
+
+func (r *BoringSpaceRepository) DeleteBoringSpace(ctx context.Context, spaceID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		posts := tx.Session(&gorm.Session{NewDB: true}).Model(&models.PublicMessage{}).Select("id").Where("boring_space_id = ?", spaceID)
+		if err := tx.Where("public_message_id IN (?)", posts).Delete(&models.Comment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM public_message_likes WHERE public_message_id IN (?)", posts).Error; err != nil {
+			return err
+		}
+		events := tx.Session(&gorm.Session{NewDB: true}).Model(&models.Event{}).Select("id").Where("boring_space_id = ?", spaceID)
+		if err := tx.Where("event_id IN (?)", events).Delete(&models.Ticket{}).Error; err != nil {
+			return err
+		}
+		channels := tx.Session(&gorm.Session{NewDB: true}).Model(&models.Chat{}).Select("id").Where("boring_space_id = ?", spaceID)
+		if err := tx.Where("chat_id IN (?)", channels).Delete(&models.Message{}).Error; err != nil {
+			return err
+		}
+
+		for _, dependent := range []interface{}{
+			&models.PublicMessage{},
+			&models.Chat{},
+			&models.Event{},
+			&models.BoringSpaceMember{},
+			&models.BoringSpaceRoleDefinition{},
+			&models.BoringSpaceInvitation{},
+			&models.BoringSpaceInviteCode{},
+			&models.BoringSpaceJoinRequest{},
+			&models.BoringSpaceSanction{},
+			&models.BoringSpaceModerationLog{},
+		} {
+			if err := tx.Where("boring_space_id = ?", spaceID).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+
+		res := tx.Delete(&models.BoringSpace{}, spaceID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *BoringSpaceRepository) TransferOwnership(ctx context.Context, spaceID uint, newOwnerID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.BoringSpaceMember{}).
+			Where("boring_space_id = ? AND user_id = ?", spaceID, newOwnerID).
+			Update("role", models.BSAdmin)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return models.ErrNotSpaceMember
+		}
+
+		return tx.Model(&models.BoringSpace{}).
+			Where("id = ?", spaceID).
+			Updates(map[string]interface{}{"creator_id": newOwnerID, "updated_at": time.Now()}).Error
+	})
 }
 
 func (r *BoringSpaceRepository) GetBoringSpaceByID(ctx context.Context, spaceID uint) (*models.BoringSpace, error) {
@@ -33,14 +117,66 @@ func (r *BoringSpaceRepository) GetBoringSpaceByID(ctx context.Context, spaceID
 	return &space, nil
 }
 
+func (r *BoringSpaceRepository) GetVisibility(ctx context.Context, spaceID uint) (models.BoringSpaceVisibility, error) {
+	var space models.BoringSpace
+	err := r.db.WithContext(ctx).
+		Select("visibility").
+		First(&space, "id = ?", spaceID).Error
+	return space.Visibility, err
+}
+
+func (r *BoringSpaceRepository) ListPublic(ctx context.Context, filter *models.BoringSpaceFilter) ([]*models.BoringSpace, error) {
+	query := r.db.WithContext(ctx).
+		Model(&models.BoringSpace{}).
+		Select("boring_spaces.*, (SELECT COUNT(*) FROM boring_space_members WHERE boring_space_members.boring_space_id = boring_spaces.id) AS member_count").
+		Where("visibility = ?", models.SpacePublic)
+
+	if filter.Query != "" {
+		pattern := "%" + likeEscaper.Replace(filter.Query) + "%"
+		query = query.Where("name ILIKE ? OR description ILIKE ?", pattern, pattern)
+	}
+
+	switch filter.Sort {
+	case models.SortByMembers:
+		query = query.Order("member_count DESC")
+	case models.SortByNewest:
+		query = query.Order("created_at DESC")
+	default:
+		query = query.Order("last_activity_at DESC")
+	}
+
+	var spaces []*models.BoringSpace
+	err := query.Order("id DESC").
+		Preload("Creator").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&spaces).Error
+	return spaces, err
+}
+
+// likeEscaper stops user input from being read as LIKE wildcards
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (r *BoringSpaceRepository) TouchActivity(ctx context.Context, spaceID uint) error {
+	return r.db.WithContext(ctx).
+		Model(&models.BoringSpace{}).
+		Where("id = ?", spaceID).
+		UpdateColumn("last_activity_at", time.Now()).Error
+}
+
 func (r *BoringSpaceRepository) AddMember(ctx context.Context, member *models.BoringSpaceMember) error {
 	return r.db.WithContext(ctx).Create(member).Error
 }
 
 func (r *BoringSpaceRepository) RemoveMember(ctx context.Context, spaceID uint, userID uint) error {
-	return r.db.WithContext(ctx).
-		Where("boring_space_id = ? AND user_id = ?", spaceID, userID).
-		Delete(&models.BoringSpaceMember{}).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ensureOtherAdmin(tx, spaceID, userID); err != nil {
+			return err
+		}
+
+		return tx.Where("boring_space_id = ? AND user_id = ?", spaceID, userID).
+			Delete(&models.BoringSpaceMember{}).Error
+	})
 }
 
 func (r *BoringSpaceRepository) GetMembers(ctx context.Context, spaceID uint) ([]*models.BoringSpaceMember, error) {
@@ -52,9 +188,91 @@ func (r *BoringSpaceRepository) GetMembers(ctx context.Context, spaceID uint) ([
 	return members, err
 }
 
+func (r *BoringSpaceRepository) GetMember(ctx context.Context, spaceID uint, userID uint) (*models.BoringSpaceMember, error) {
+	var member models.BoringSpaceMember
+	err := r.db.WithContext(ctx).
+		Where("boring_space_id = ? AND user_id = ?", spaceID, userID).
+		First(&member).Error
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
 func (r *BoringSpaceRepository) UpdateMemberRole(ctx context.Context, spaceID uint, userID uint, role models.BoringSpaceRole) error {
-	return r.db.WithContext(ctx).
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if role != models.BSAdmin {
+			if err := ensureOtherAdmin(tx, spaceID, userID); err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.BoringSpaceMember{}).
+			Where("boring_space_id = ? AND user_id = ?", spaceID, userID).
+			Update("role", role).Error
+	})
+}
+
+// ensureOtherAdmin fails with models.ErrLastSpaceAdmin if userID is the space's only admin.
+// The admin rows stay locked until tx ends, so two admins can't both step down at once.
+func ensureOtherAdmin(tx *gorm.DB, spaceID uint, userID uint) error {
+	var admins []uint
+	err := tx.Model(&models.BoringSpaceMember{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("boring_space_id = ? AND role = ?", spaceID, models.BSAdmin).
+		Pluck("user_id", &admins).Error
+	if err != nil {
+		return err
+	}
+
+	for _, admin := range admins {
+		if admin == userID && len(admins) == 1 {
+			return models.ErrLastSpaceAdmin
+		}
+	}
+	return nil
+}
+
+func (r *BoringSpaceRepository) CountMembersWithRole(ctx context.Context, spaceID uint, role models.BoringSpaceRole) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
 		Model(&models.BoringSpaceMember{}).
-		Where("boring_space_id = ? AND user_id = ?", spaceID, userID).
-		Update("role", role).Error
+		Where("boring_space_id = ? AND role = ?", spaceID, role).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *BoringSpaceRepository) GetRoleDefinitions(ctx context.Context, spaceID uint) ([]*models.BoringSpaceRoleDefinition, error) {
+	var definitions []*models.BoringSpaceRoleDefinition
+	err := r.db.WithContext(ctx).
+		Where("boring_space_id = ?", spaceID).
+		Order("name").
+		Find(&definitions).Error
+	return definitions, err
+}
+
+func (r *BoringSpaceRepository) GetRoleDefinition(ctx context.Context, spaceID uint, name models.BoringSpaceRole) (*models.BoringSpaceRoleDefinition, error) {
+	var definition models.BoringSpaceRoleDefinition
+	err := r.db.WithContext(ctx).
+		Where("boring_space_id = ? AND name = ?", spaceID, name).
+		First(&definition).Error
+	if err != nil {
+		return nil, err
+	}
+	return &definition, nil
+}
+
+func (r *BoringSpaceRepository) SaveRoleDefinition(ctx context.Context, definition *models.BoringSpaceRoleDefinition) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "boring_space_id"}, {Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"permissions", "updated_at"}),
+		}).
+		Create(definition).Error
+}
+
+func (r *BoringSpaceRepository) DeleteRoleDefinition(ctx context.Context, spaceID uint, name models.BoringSpaceRole) error {
+	return r.db.WithContext(ctx).
+		Where("boring_space_id = ? AND name = ?", spaceID, name).
+		Delete(&models.BoringSpaceRoleDefinition{}).Error
 }
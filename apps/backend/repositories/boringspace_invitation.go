@@ -0,0 +1,204 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/montekkundan/bored/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BoringSpaceInvitationRepository struct {
+	db *gorm.DB
+}
+
+func NewBoringSpaceInvitationRepository(db *gorm.DB) models.BoringSpaceInvitationRepository {
+	return &BoringSpaceInvitationRepository{
+		db: db,
+	}
+}
+
+func (r *BoringSpaceInvitationRepository) CreateInvitation(ctx context.Context, invitation *models.BoringSpaceInvitation) error {
+	return r.db.WithContext(ctx).Create(invitation).Error
+}
+
+func (r *BoringSpaceInvitationRepository) GetInvitation(ctx context.Context, invitationID uint) (*models.BoringSpaceInvitation, error) {
+	var invitation models.BoringSpaceInvitation
+	err := r.db.WithContext(ctx).
+		Preload("BoringSpace").
+		First(&invitation, "id = ?", invitationID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *BoringSpaceInvitationRepository) GetPendingInvitation(ctx context.Context, spaceID uint, inviteeID uint) (*models.BoringSpaceInvitation, error) {
+	var invitation models.BoringSpaceInvitation
+	err := r.db.WithContext(ctx).
+		Where("boring_space_id = ? AND invitee_id = ? AND status = ?", spaceID, inviteeID, models.InvitationPending).
+		First(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *BoringSpaceInvitationRepository) GetSpaceInvitations(ctx context.Context, spaceID uint) ([]*models.BoringSpaceInvitation, error) {
+	var invitations []*models.BoringSpaceInvitation
+	err := r.db.WithContext(ctx).
+		Where("boring_space_id = ? AND status = ?", spaceID, models.InvitationPending).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *BoringSpaceInvitationRepository) GetUserInvitations(ctx context.Context, userID uint) ([]*models.BoringSpaceInvitation, error) {
+	var invitations []*models.BoringSpaceInvitation
+	err := r.db.WithContext(ctx).
+		Preload("BoringSpace").
+		Where("invitee_id = ? AND status = ?", userID, models.InvitationPending).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *BoringSpaceInvitationRepository) CloseInvitation(ctx context.Context, invitationID uint, status models.InvitationStatus, member *models.BoringSpaceMember) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.BoringSpaceInvitation{}).
+			Where("id = ? AND status = ?", invitationID, models.InvitationPending).
+			Updates(map[string]interface{}{"status": status, "responded_at": time.Now()})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return models.ErrInvitationClosed
+		}
+
+		if member == nil {
+			return nil
+		}
+		return tx.Create(member).Error
+	})
+}
+
+func (r *BoringSpaceInvitationRepository) CreateInviteCode(ctx context.Context, code *models.BoringSpaceInviteCode) error {
+	return r.db.WithContext(ctx).Create(code).Error
+}
+
+func (r *BoringSpaceInvitationRepository) GetInviteCodes(ctx context.Context, spaceID uint) ([]*models.BoringSpaceInviteCode, error) {
+	var codes []*models.BoringSpaceInviteCode
+	err := r.db.WithContext(ctx).
+		Where("boring_space_id = ? AND revoked_at IS NULL", spaceID).
+		Order("created_at DESC").
+		Find(&codes).Error
+	return codes, err
+}
+
+func (r *BoringSpaceInvitationRepository) GetInviteCode(ctx context.Context, code string) (*models.BoringSpaceInviteCode, error) {
+	var inviteCode models.BoringSpaceInviteCode
+	if err := r.db.WithContext(ctx).First(&inviteCode, "code = ?", code).Error; err != nil {
+		return nil, err
+	}
+	return &inviteCode, nil
+}
+
+func (r *BoringSpaceInvitationRepository) RevokeInviteCode(ctx context.Context, spaceID uint, codeID uint) error {
+	res := r.db.WithContext(ctx).
+		Model(&models.BoringSpaceInviteCode{}).
+		Where("id = ? AND boring_space_id = ? AND revoked_at IS NULL", codeID, spaceID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *BoringSpaceInvitationRepository) RedeemInviteCode(ctx context.Context, code string, userID uint) (*models.BoringSpaceInviteCode, *models.BoringSpaceMember, error) {
+	var inviteCode models.BoringSpaceInviteCode
+	var member *models.BoringSpaceMember
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The conditional update claims a use, so two people can't both take the last one
+		res := tx.Model(&inviteCode).
+			Clauses(clause.Returning{}).
+			Where("code = ? AND revoked_at IS NULL", code).
+			Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+			Where("max_uses = 0 OR uses < max_uses").
+			Update("uses", gorm.Expr("uses + 1"))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return models.ErrInvalidInviteCode
+		}
+
+		member = &models.BoringSpaceMember{
+			BoringSpaceID: inviteCode.BoringSpaceID,
+			UserID:        userID,
+			Role:          inviteCode.Role,
+		}
+		return tx.Create(member).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &inviteCode, member, nil
+}
+
+func (r *BoringSpaceInvitationRepository) CreateJoinRequest(ctx context.Context, request *models.BoringSpaceJoinRequest) error {
+	return r.db.WithContext(ctx).Create(request).Error
+}
+
+func (r *BoringSpaceInvitationRepository) GetJoinRequest(ctx context.Context, requestID uint) (*models.BoringSpaceJoinRequest, error) {
+	var request models.BoringSpaceJoinRequest
+	if err := r.db.WithContext(ctx).First(&request, "id = ?", requestID).Error; err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *BoringSpaceInvitationRepository) GetPendingJoinRequest(ctx context.Context, spaceID uint, userID uint) (*models.BoringSpaceJoinRequest, error) {
+	var request models.BoringSpaceJoinRequest
+	err := r.db.WithContext(ctx).
+		Where("boring_space_id = ? AND user_id = ? AND status = ?", spaceID, userID, models.JoinRequestPending).
+		First(&request).Error
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (r *BoringSpaceInvitationRepository) GetJoinRequests(ctx context.Context, spaceID uint) ([]*models.BoringSpaceJoinRequest, error) {
+	var requests []*models.BoringSpaceJoinRequest
+	err := r.db.WithContext(ctx).
+		Preload("User").
+		Where("boring_space_id = ? AND status = ?", spaceID, models.JoinRequestPending).
+		Order("created_at").
+		Find(&requests).Error
+	return requests, err
+}
+
+func (r *BoringSpaceInvitationRepository) ReviewJoinRequest(ctx context.Context, requestID uint, status models.JoinRequestStatus, reviewerID uint, member *models.BoringSpaceMember) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.BoringSpaceJoinRequest{}).
+			Where("id = ? AND status = ?", requestID, models.JoinRequestPending).
+			Updates(map[string]interface{}{"status": status, "reviewer_id": reviewerID, "reviewed_at": time.Now()})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return models.ErrJoinRequestReviewed
+		}
+
+		if member == nil {
+			return nil
+		}
+		return tx.Create(member).Error
+	})
+}
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/montekkundan/bored/backend/models"
+	"gorm.io/gorm"
+)
+
+type BoringSpaceModerationRepository struct {
+	db *gorm.DB
+}
+
+func NewBoringSpaceModerationRepository(db *gorm.DB) models.BoringSpaceModerationRepository {
+	return &BoringSpaceModerationRepository{
+		db: db,
+	}
+}
+
+func (r *BoringSpaceModerationRepository) AddSanction(ctx context.Context, sanction *models.BoringSpaceSanction, entry *models.BoringSpaceModerationLog) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// A new mute replaces the old one rather than stacking with it
+		err := activeSanctions(tx.Model(&models.BoringSpaceSanction{})).
+			Where("boring_space_id = ? AND user_id = ? AND type = ?", sanction.BoringSpaceID, sanction.UserID, sanction.Type).
+			Updates(map[string]interface{}{"lifted_at": time.Now(), "lifted_by_id": sanction.ModeratorID}).Error
+		if err != nil {
+			return err
+		}
+
+		if sanction.Type == models.SpaceBan {
+			err := tx.Where("boring_space_id = ? AND user_id = ?", sanction.BoringSpaceID, sanction.UserID).
+				Delete(&models.BoringSpaceMember{}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(sanction).Error; err != nil {
+			return err
+		}
+		return tx.Create(entry).Error
+	})
+}
+
+func (r *BoringSpaceModerationRepository) LiftSanction(ctx context.Context, spaceID uint, userID uint, sanctionType models.SpaceSanctionType, entry *models.BoringSpaceModerationLog) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := activeSanctions(tx.Model(&models.BoringSpaceSanction{})).
+			Where("boring_space_id = ? AND user_id = ? AND type = ?", spaceID, userID, sanctionType).
+			Updates(map[string]interface{}{"lifted_at": time.Now(), "lifted_by_id": entry.ModeratorID})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return models.ErrSpaceSanctionMissing
+		}
+		return tx.Create(entry).Error
+	})
+}
+
+func (r *BoringSpaceModerationRepository) GetActiveSanctions(ctx context.Context, spaceID uint, userID uint) ([]*models.BoringSpaceSanction, error) {
+	var sanctions []*models.BoringSpaceSanction
+	err := activeSanctions(r.db.WithContext(ctx)).
+		Where("boring_space_id = ? AND user_id = ?", spaceID, userID).
+		Find(&sanctions).Error
+	return sanctions, err
+}
+
+func (r *BoringSpaceModerationRepository) GetSpaceSanctions(ctx context.Context, spaceID uint) ([]*models.BoringSpaceSanction, error) {
+	var sanctions []*models.BoringSpaceSanction
+	err := activeSanctions(r.db.WithContext(ctx)).
+		Preload("User").
+		Where("boring_space_id = ?", spaceID).
+		Order("created_at DESC").
+		Find(&sanctions).Error
+	return sanctions, err
+}
+
+func (r *BoringSpaceModerationRepository) CreateLogEntry(ctx context.Context, entry *models.BoringSpaceModerationLog) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+func (r *BoringSpaceModerationRepository) GetLog(ctx context.Context, spaceID uint, limit, offset int) ([]*models.BoringSpaceModerationLog, error) {
+	var entries []*models.BoringSpaceModerationLog
+	err := r.db.WithContext(ctx).
+		Preload("Moderator").
+		Preload("TargetUser").
+		Where("boring_space_id = ?", spaceID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&entries).Error
+	return entries, err
+}
+
+// activeSanctions narrows a query to sanctions that are neither lifted nor expired, so expiry needs no cleanup job
+func activeSanctions(db *gorm.DB) *gorm.DB {
+	return db.Where("lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now())
+}
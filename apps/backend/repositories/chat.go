@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/montekkundan/bored/backend/models"
 	"gorm.io/gorm"
@@ -15,15 +16,51 @@ func (r *ChatRepository) CreateChat(ctx context.Context, chat *models.Chat) erro
 	return r.db.Create(chat).Error
 }
 
+func (r *ChatRepository) GetChat(ctx context.Context, chatID uint) (*models.Chat, error) {
+	var chat models.Chat
+	if err := r.db.WithContext(ctx).First(&chat, chatID).Error; err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+func (r *ChatRepository) GetSpaceChats(ctx context.Context, spaceID uint, includeArchived bool) ([]*models.Chat, error) {
+	var chats []*models.Chat
+	query := r.db.WithContext(ctx).Where("boring_space_id = ?", spaceID)
+	if !includeArchived {
+		query = query.Where("archived_at IS NULL")
+	}
+	err := query.Order("created_at").Find(&chats).Error
+	return chats, err
+}
+
+func (r *ChatRepository) ArchiveChat(ctx context.Context, chatID uint) error {
+	res := r.db.WithContext(ctx).
+		Model(&models.Chat{}).
+		Where("id = ? AND archived_at IS NULL", chatID).
+		Update("archived_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return models.ErrChannelArchived
+	}
+	return nil
+}
+
 func (r *ChatRepository) AddMember(ctx context.Context, chatID uint, userID uint) error {
 	member := &models.ChatMember{ChatID: chatID, UserID: userID}
 	return r.db.Create(member).Error
 }
 
-func (r *ChatRepository) GetMessages(ctx context.Context, chatID uint) ([]*models.Message, error) {
+func (r *ChatRepository) GetMessages(ctx context.Context, chatID uint, page *models.PageRequest) ([]*models.Message, string, error) {
 	var messages []*models.Message
-	err := r.db.Where("chat_id = ?", chatID).Order("created_at asc").Find(&messages).Error
-	return messages, err
+	if err := paginate(r.db.Where("chat_id = ?", chatID), page, true).Find(&messages).Error; err != nil {
+		return nil, "", err
+	}
+
+	messages, next := nextPage(messages, page)
+	return messages, next, nil
 }
 
 func (r *ChatRepository) SendMessage(ctx context.Context, message *models.Message) error {
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/montekkundan/bored/backend/models"
 	"gorm.io/gorm"
@@ -14,7 +15,22 @@ type EventRepository struct {
 func (r *EventRepository) GetMany(ctx context.Context) ([]*models.Event, error) {
 	events := []*models.Event{}
 
-	res := r.db.Model(&models.Event{}).Order("updated_at desc").Find(&events)
+	res := r.db.Model(&models.Event{}).Scopes(notInPrivateSpace).Order("updated_at desc").Find(&events)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	return events, nil
+}
+
+func (r *EventRepository) GetSpaceEvents(ctx context.Context, spaceID uint, from, to time.Time) ([]*models.Event, error) {
+	events := []*models.Event{}
+
+	res := r.db.WithContext(ctx).
+		Where("boring_space_id = ? AND date >= ? AND date < ?", spaceID, from, to).
+		Order("date asc, id asc").
+		Find(&events)
 
 	if res.Error != nil {
 		return nil, res.Error
@@ -19,10 +19,14 @@ func (r *NotificationRepository) MarkAsRead(ctx context.Context, notificationID
 	return r.db.Model(&models.Notification{}).Where("id = ?", notificationID).Update("is_read", true).Error
 }
 
-func (r *NotificationRepository) GetNotifications(ctx context.Context, userID uint) ([]*models.Notification, error) {
+func (r *NotificationRepository) GetNotifications(ctx context.Context, userID uint, page *models.PageRequest) ([]*models.Notification, string, error) {
 	var notifications []*models.Notification
-	err := r.db.Where("user_id = ?", userID).Order("created_at desc").Find(&notifications).Error
-	return notifications, err
+	if err := paginate(r.db.Where("user_id = ?", userID), page, false).Find(&notifications).Error; err != nil {
+		return nil, "", err
+	}
+
+	notifications, next := nextPage(notifications, page)
+	return notifications, next, nil
 }
 
 func NewNotificationRepository(db *gorm.DB) models.NotificationRepository {
@@ -12,15 +12,38 @@ type OAuthProviderRepository struct {
 }
 
 func (r *OAuthProviderRepository) AddProvider(ctx context.Context, provider *models.OAuthProvider) error {
-	return r.db.Create(provider).Error
+	return r.db.WithContext(ctx).Create(provider).Error
 }
 
 func (r *OAuthProviderRepository) GetProvidersByUser(ctx context.Context, userID uint) ([]*models.OAuthProvider, error) {
 	var providers []*models.OAuthProvider
-	err := r.db.Where("user_id = ?", userID).Find(&providers).Error
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&providers).Error
 	return providers, err
 }
 
+func (r *OAuthProviderRepository) GetByProviderID(ctx context.Context, provider string, providerID string) (*models.OAuthProvider, error) {
+	link := &models.OAuthProvider{}
+	if err := r.db.WithContext(ctx).Where("provider = ? AND provider_id = ?", provider, providerID).First(link).Error; err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+func (r *OAuthProviderRepository) UpdateToken(ctx context.Context, id uint, token string) error {
+	return r.db.WithContext(ctx).Model(&models.OAuthProvider{}).Where("id = ?", id).Update("token", token).Error
+}
+
+func (r *OAuthProviderRepository) DeleteProvider(ctx context.Context, userID uint, id uint) error {
+	res := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&models.OAuthProvider{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
 func NewOAuthProviderRepository(db *gorm.DB) models.OAuthProviderRepository {
 	return &OAuthProviderRepository{db: db}
 }
@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"github.com/montekkundan/bored/backend/models"
+	"gorm.io/gorm"
+)
+
+// paginate orders query by (created_at, id), newest first unless oldestFirst, and picks up after
+// the page's cursor. It asks for one row more than the limit so nextPage can tell if there is more.
+func paginate(query *gorm.DB, page *models.PageRequest, oldestFirst bool) *gorm.DB {
+	direction, comparison := "DESC", "<"
+	if oldestFirst {
+		direction, comparison = "ASC", ">"
+	}
+
+	if page.After != nil {
+		query = query.Where("(created_at, id) "+comparison+" (?, ?)", page.After.CreatedAt, page.After.ID)
+	}
+	return query.
+		Order("created_at " + direction).
+		Order("id " + direction).
+		Limit(page.Limit + 1)
+}
+
+// nextPage drops the extra row paginate fetched, returning the cursor for the following page,
+// or "" on the last one
+func nextPage[T models.Paged](rows []T, page *models.PageRequest) ([]T, string) {
+	if len(rows) <= page.Limit {
+		return rows, ""
+	}
+
+	rows = rows[:page.Limit]
+	return rows, rows[len(rows)-1].Cursor().Encode()
+}
@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/montekkundan/bored/backend/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type pagedRow struct {
+	ID        uint
+	CreatedAt time.Time
+}
+
+func (r pagedRow) Cursor() models.Cursor {
+	return models.Cursor{CreatedAt: r.CreatedAt, ID: r.ID}
+}
+
+func rows(n int) []pagedRow {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	out := make([]pagedRow, n)
+	for i := range out {
+		out[i] = pagedRow{ID: uint(i + 1), CreatedAt: start.Add(time.Duration(i) * time.Minute)}
+	}
+	return out
+}
+
+func TestNextPage(t *testing.T) {
+	page := &models.PageRequest{Limit: 3}
+
+	tests := []struct {
+		name     string
+		rows     []pagedRow
+		wantLen  int
+		wantNext bool
+	}{
+		{"empty", rows(0), 0, false},
+		{"short page", rows(2), 2, false},
+		{"exactly the limit", rows(3), 3, false},
+		{"one extra row", rows(4), 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, next := nextPage(tt.rows, page)
+			if len(got) != tt.wantLen {
+				t.Errorf("got %d rows, want %d", len(got), tt.wantLen)
+			}
+			if (next != "") != tt.wantNext {
+				t.Fatalf("next cursor = %q, want one: %v", next, tt.wantNext)
+			}
+			if next == "" {
+				return
+			}
+
+			// The next page picks up after the last row returned, not the extra one
+			cursor, err := models.DecodeCursor(next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := got[len(got)-1]
+			if cursor.ID != last.ID || !cursor.CreatedAt.Equal(last.CreatedAt) {
+				t.Errorf("next cursor points at %v, want the last row %v", *cursor, last)
+			}
+		})
+	}
+}
+
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestPaginate(t *testing.T) {
+	after := &models.Cursor{CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), ID: 9}
+
+	tests := []struct {
+		name        string
+		page        *models.PageRequest
+		oldestFirst bool
+		wantSQL     string
+		wantVars    []interface{}
+	}{
+		{
+			name:     "first page newest first",
+			page:     &models.PageRequest{Limit: 20},
+			wantSQL:  `SELECT * FROM "messages" ORDER BY created_at DESC,id DESC LIMIT $1`,
+			wantVars: []interface{}{21},
+		},
+		{
+			name:     "later page newest first",
+			page:     &models.PageRequest{Limit: 20, After: after},
+			wantSQL:  `SELECT * FROM "messages" WHERE (created_at, id) < ($1, $2) ORDER BY created_at DESC,id DESC LIMIT $3`,
+			wantVars: []interface{}{after.CreatedAt, after.ID, 21},
+		},
+		{
+			name:        "later page oldest first",
+			page:        &models.PageRequest{Limit: 5, After: after},
+			oldestFirst: true,
+			wantSQL:     `SELECT * FROM "messages" WHERE (created_at, id) > ($1, $2) ORDER BY created_at ASC,id ASC LIMIT $3`,
+			wantVars:    []interface{}{after.CreatedAt, after.ID, 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var found []pagedRow
+			stmt := paginate(dryRunDB(t).Table("messages"), tt.page, tt.oldestFirst).Find(&found).Statement
+
+			if sql := stmt.SQL.String(); sql != tt.wantSQL {
+				t.Errorf("query = %s, want %s", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(stmt.Vars, tt.wantVars) {
+				t.Errorf("vars = %v, want %v", stmt.Vars, tt.wantVars)
+			}
+		})
+	}
+}
@@ -24,22 +24,50 @@ func (r *PublicMessageRepository) GetPublicMessageByID(ctx context.Context, mess
 	err := r.db.WithContext(ctx).
 		Preload("User").
 		Preload("Likes").
-		Preload("Comments.User").
+		Scopes(withCommentCount).
 		First(&message, messageID).Error
 	return &message, err
 }
 
-func (r *PublicMessageRepository) GetPublicMessages(ctx context.Context, limit, offset int) ([]*models.PublicMessage, error) {
+func (r *PublicMessageRepository) GetPublicMessages(ctx context.Context, page *models.PageRequest) ([]*models.PublicMessage, string, error) {
 	var messages []*models.PublicMessage
-	err := r.db.WithContext(ctx).
+	query := r.db.WithContext(ctx).
 		Preload("User").
 		Preload("Likes").
-		Preload("Comments.User").
-		Order("created_at DESC").
-		Limit(limit).
-		Offset(offset).
-		Find(&messages).Error
-	return messages, err
+		Scopes(withCommentCount, notInPrivateSpace)
+	if err := paginate(query, page, false).Find(&messages).Error; err != nil {
+		return nil, "", err
+	}
+
+	messages, next := nextPage(messages, page)
+	return messages, next, nil
+}
+
+func (r *PublicMessageRepository) GetSpaceMessages(ctx context.Context, spaceID uint, page *models.PageRequest) ([]*models.PublicMessage, string, error) {
+	var messages []*models.PublicMessage
+	query := r.db.WithContext(ctx).
+		Preload("User").
+		Preload("Likes").
+		Scopes(withCommentCount).
+		Where("boring_space_id = ?", spaceID)
+	if err := paginate(query, page, false).Find(&messages).Error; err != nil {
+		return nil, "", err
+	}
+
+	messages, next := nextPage(messages, page)
+	return messages, next, nil
+}
+
+// withCommentCount fills CommentCount instead of loading every comment of every message
+func withCommentCount(db *gorm.DB) *gorm.DB {
+	return db.Select("public_messages.*, (?) AS comment_count",
+		db.Session(&gorm.Session{NewDB: true}).Model(&models.Comment{}).Select("COUNT(*)").Where("comments.public_message_id = public_messages.id"))
+}
+
+// notInPrivateSpace keeps rows that belong to private spaces out of lists anyone can read
+func notInPrivateSpace(db *gorm.DB) *gorm.DB {
+	return db.Where("boring_space_id IS NULL OR boring_space_id NOT IN (?)",
+		db.Session(&gorm.Session{NewDB: true}).Model(&models.BoringSpace{}).Select("id").Where("visibility = ?", models.SpacePrivate))
 }
 
 func (r *PublicMessageRepository) DeletePublicMessage(ctx context.Context, messageID uint) error {
@@ -68,12 +96,15 @@ func (r *PublicMessageRepository) CreateComment(ctx context.Context, comment *mo
 	return r.db.WithContext(ctx).Create(comment).Error
 }
 
-func (r *PublicMessageRepository) GetCommentsByMessageID(ctx context.Context, messageID uint) ([]*models.Comment, error) {
+func (r *PublicMessageRepository) GetCommentsByMessageID(ctx context.Context, messageID uint, page *models.PageRequest) ([]*models.Comment, string, error) {
 	var comments []*models.Comment
-	err := r.db.WithContext(ctx).
+	query := r.db.WithContext(ctx).
 		Preload("User").
-		Where("public_message_id = ?", messageID).
-		Order("created_at ASC").
-		Find(&comments).Error
-	return comments, err
+		Where("public_message_id = ?", messageID)
+	if err := paginate(query, page, true).Find(&comments).Error; err != nil {
+		return nil, "", err
+	}
+
+	comments, next := nextPage(comments, page)
+	return comments, next, nil
 }
@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/montekkundan/bored/backend/models"
+	"gorm.io/gorm"
+)
+
+// lastQuery records the SQL of the last query db runs against public_messages
+func lastQuery(t *testing.T, db *gorm.DB) *string {
+	t.Helper()
+	var sql string
+	err := db.Callback().Query().After("gorm:query").Register("test:last_query", func(tx *gorm.DB) {
+		if tx.Statement.Table == "public_messages" {
+			sql = tx.Statement.SQL.String()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &sql
+}
+
+func TestFeedsCountComments(t *testing.T) {
+	const commentCount = `(SELECT COUNT(*) FROM "comments" WHERE comments.public_message_id = public_messages.id) AS comment_count`
+	ctx := context.Background()
+	page := &models.PageRequest{Limit: 20}
+
+	tests := []struct {
+		name  string
+		query func(db *gorm.DB) error
+	}{
+		{"message", func(db *gorm.DB) error {
+			_, err := NewPublicMessageRepository(db).GetPublicMessageByID(ctx, 1)
+			return err
+		}},
+		{"global feed", func(db *gorm.DB) error {
+			_, _, err := NewPublicMessageRepository(db).GetPublicMessages(ctx, page)
+			return err
+		}},
+		{"space feed", func(db *gorm.DB) error {
+			_, _, err := NewPublicMessageRepository(db).GetSpaceMessages(ctx, 1, page)
+			return err
+		}},
+		{"all public messages", func(db *gorm.DB) error {
+			_, _, err := NewUserRepository(db).GetAllPublicMessages(ctx, page)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dryRunDB(t)
+			sql := lastQuery(t, db)
+			if err := tt.query(db); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(*sql, "SELECT public_messages.*, "+commentCount+" FROM") {
+				t.Errorf("query = %s, want it to select the comment count", *sql)
+			}
+		})
+	}
+}
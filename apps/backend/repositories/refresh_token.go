@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/montekkundan/bored/backend/models"
 	"gorm.io/gorm"
@@ -19,16 +20,61 @@ func (r *RefreshTokenRepository) Create(ctx context.Context, refreshToken *model
 	return r.db.WithContext(ctx).Create(refreshToken).Error
 }
 
-func (r *RefreshTokenRepository) FindByToken(ctx context.Context, token string) (*models.RefreshToken, error) {
+func (r *RefreshTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
 	var refreshToken models.RefreshToken
-	if err := r.db.WithContext(ctx).Where("token = ?", token).First(&refreshToken).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&refreshToken).Error; err != nil {
 		return nil, err
 	}
 	return &refreshToken, nil
 }
 
-func (r *RefreshTokenRepository) Delete(ctx context.Context, token string) error {
-	return r.db.WithContext(ctx).Where("token = ?", token).Delete(&models.RefreshToken{}).Error
+// Rotate marks current as used and stores next in a single transaction.
+// If current was already rotated or revoked concurrently, it returns models.ErrRefreshTokenReused.
+func (r *RefreshTokenRepository) Rotate(ctx context.Context, current *models.RefreshToken, next *models.RefreshToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", current.ID).
+			Update("rotated_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return models.ErrRefreshTokenReused
+		}
+
+		return tx.Create(next).Error
+	})
+}
+
+func (r *RefreshTokenRepository) Delete(ctx context.Context, tokenHash string) error {
+	return r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).Delete(&models.RefreshToken{}).Error
+}
+
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// ListActive returns the current token of every live session, most recently used first
+func (r *RefreshTokenRepository) ListActive(ctx context.Context, userID uint) ([]*models.RefreshToken, error) {
+	var tokens []*models.RefreshToken
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *RefreshTokenRepository) RevokeUserFamilies(ctx context.Context, userID uint, familyIDs []string) error {
+	if len(familyIDs) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("user_id = ? AND family_id IN ? AND revoked_at IS NULL", userID, familyIDs).
+		Update("revoked_at", time.Now()).Error
 }
 
 func (r *RefreshTokenRepository) InvalidateOldTokens(ctx context.Context, userID uint) error {
@@ -55,6 +55,14 @@ func (r *UserRepository) DeactivateUser(ctx context.Context, userID uint) error
 	return r.db.Model(&models.User{}).Where("id = ?", userID).Update("deactivated", true).Error
 }
 
+// IncrementTokenVersion invalidates every token minted with the previous version
+func (r *UserRepository) IncrementTokenVersion(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("token_version", gorm.Expr("token_version + 1")).Error
+}
+
 func (r *UserRepository) GetUserBoringSpaces(ctx context.Context, userID uint) ([]*models.BoringSpaceMember, error) {
 	var memberships []*models.BoringSpaceMember
 	err := r.db.WithContext(ctx).
@@ -67,17 +75,18 @@ func (r *UserRepository) GetUserBoringSpaces(ctx context.Context, userID uint) (
 	return memberships, nil
 }
 
-func (r *UserRepository) GetAllPublicMessages(ctx context.Context, limit, offset int) ([]*models.PublicMessage, error) {
+func (r *UserRepository) GetAllPublicMessages(ctx context.Context, page *models.PageRequest) ([]*models.PublicMessage, string, error) {
 	var messages []*models.PublicMessage
-	err := r.db.WithContext(ctx).
+	query := r.db.WithContext(ctx).
 		Preload("User").
 		Preload("Likes").
-		Preload("Comments").
-		Order("created_at DESC").
-		Limit(limit).
-		Offset(offset).
-		Find(&messages).Error
-	return messages, err
+		Scopes(withCommentCount, notInPrivateSpace)
+	if err := paginate(query, page, false).Find(&messages).Error; err != nil {
+		return nil, "", err
+	}
+
+	messages, next := nextPage(messages, page)
+	return messages, next, nil
 }
 
 func NewUserRepository(db *gorm.DB) models.UserRepository {
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/montekkundan/bored/backend/models"
+	"gorm.io/gorm"
+)
+
+type VerificationTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewVerificationTokenRepository(db *gorm.DB) *VerificationTokenRepository {
+	return &VerificationTokenRepository{db: db}
+}
+
+func (r *VerificationTokenRepository) Create(ctx context.Context, token *models.VerificationToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// Consume marks an unused, unexpired token as used. It returns gorm.ErrRecordNotFound otherwise.
+func (r *VerificationTokenRepository) Consume(ctx context.Context, purpose models.VerificationPurpose, tokenHash string) (*models.VerificationToken, error) {
+	var token models.VerificationToken
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", purpose, tokenHash, time.Now()).
+			First(&token).Error; err != nil {
+			return err
+		}
+
+		res := tx.Model(&token).Where("used_at IS NULL").Update("used_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *VerificationTokenRepository) DeleteForUser(ctx context.Context, userID uint, purpose models.VerificationPurpose) error {
+	return r.db.WithContext(ctx).
+		Where("user_id = ? AND purpose = ?", userID, purpose).
+		Delete(&models.VerificationToken{}).Error
+}
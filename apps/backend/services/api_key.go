@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/utils"
+	"gorm.io/gorm"
+)
+
+// lastUsedResolution limits last-used writes to one per key per minute
+const lastUsedResolution = time.Minute
+
+type APIKeyService struct {
+	repository  models.APIKeyRepository
+	userService models.UserService
+}
+
+// CreateKey returns the raw key, which is only ever available here, and the stored record
+func (s *APIKeyService) CreateKey(ctx context.Context, userID uint, req *models.CreateAPIKeyRequest) (string, *models.APIKey, error) {
+	for _, scope := range req.Scopes {
+		if !models.IsValidAPIKeyScope(scope) {
+			return "", nil, fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	rawKey := models.APIKeyPrefix + secret
+
+	key := &models.APIKey{
+		UserID:  userID,
+		Name:    req.Name,
+		Prefix:  rawKey[:len(models.APIKeyPrefix)+6],
+		KeyHash: utils.HashToken(rawKey),
+		Scopes:  req.Scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().Add(time.Hour * 24 * time.Duration(req.ExpiresInDays))
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := s.repository.Create(ctx, key); err != nil {
+		return "", nil, err
+	}
+
+	return rawKey, key, nil
+}
+
+func (s *APIKeyService) GetKeys(ctx context.Context, userID uint) ([]*models.APIKey, error) {
+	return s.repository.GetByUser(ctx, userID)
+}
+
+func (s *APIKeyService) RevokeKey(ctx context.Context, userID uint, keyID uint) error {
+	return s.repository.Revoke(ctx, userID, keyID)
+}
+
+// Authenticate resolves a raw key to a live key record and records its use
+func (s *APIKeyService) Authenticate(ctx context.Context, rawKey string, ip string) (*models.APIKey, error) {
+	key, err := s.repository.FindByHash(ctx, utils.HashToken(rawKey))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now())) {
+		return nil, models.ErrInvalidAPIKey
+	}
+
+	// Keys die with their owner and stop working while the account is deactivated
+	user, err := s.userService.GetUserByID(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if user.Deactivated {
+		return nil, models.ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > lastUsedResolution || key.LastUsedIP != ip {
+		if err := s.repository.TouchLastUsed(ctx, key.ID, ip); err != nil {
+			log.Errorf("Unable to record use of api key %d: %v", key.ID, err)
+		}
+	}
+
+	return key, nil
+}
+
+func NewAPIKeyService(repository models.APIKeyRepository, userService models.UserService) models.APIKeyService {
+	return &APIKeyService{
+		repository:  repository,
+		userService: userService,
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/montekkundan/bored/backend/models"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+type AuditService struct {
+	repository models.AuditLogRepository
+}
+
+func (s *AuditService) Record(ctx context.Context, entry *models.AuditLog) {
+	// Detach from the request so a cancelled request can't drop the entry
+	ctx = context.WithoutCancel(ctx)
+
+	if err := s.repository.Create(ctx, entry); err != nil {
+		log.Errorf("Unable to record %s audit event: %v", entry.Type, err)
+	}
+}
+
+func (s *AuditService) Query(ctx context.Context, filter *models.AuditLogFilter) ([]*models.AuditLog, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditPageSize
+	}
+	if filter.Limit > maxAuditPageSize {
+		filter.Limit = maxAuditPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	return s.repository.Find(ctx, filter)
+}
+
+// RecentActivity is what a user sees about their own account
+func (s *AuditService) RecentActivity(ctx context.Context, userID uint, limit int) ([]*models.AuditLog, error) {
+	return s.Query(ctx, &models.AuditLogFilter{UserID: &userID, Limit: limit})
+}
+
+func NewAuditService(repository models.AuditLogRepository) models.AuditService {
+	return &AuditService{repository: repository}
+}
@@ -4,15 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/gofiber/fiber/v2/log"
 	"github.com/montekkundan/bored/backend/config"
 	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/signing"
 	"github.com/montekkundan/bored/backend/utils"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -20,14 +20,34 @@ type AuthService struct {
 	repository       models.AuthRepository
 	userService      models.UserService
 	refreshTokenRepo models.RefreshTokenRepository
+	recoveryCodeRepo models.RecoveryCodeRepository
+	apiKeyRepo       models.APIKeyRepository
 	redisClient      *redis.Client
 	config           *config.EnvConfig
+	keys             *signing.KeyManager
+	audit            models.AuditService
+	hasher           models.PasswordHasher
+	passwordPolicy   models.PasswordPolicy
+
+	verificationTokenRepo models.VerificationTokenRepository
+	mailer                models.Mailer
+	smsSender             models.SMSSender
 }
 
-func (s *AuthService) Login(ctx context.Context, loginData *models.AuthCredentials) (map[string]string, *models.User, error) {
+func (s *AuthService) Login(ctx context.Context, loginData *models.AuthCredentials, device *models.DeviceInfo) (map[string]string, *models.User, error) {
 	var user *models.User
 	var err error
 
+	// Refuse before touching the database so a locked IP can't keep probing
+	if err := s.checkLockout(ctx, models.LoginLockout, 0, device.IPAddress); err != nil {
+		return nil, nil, err
+	}
+
+	identifier := loginData.Username
+	if identifier == "" {
+		identifier = loginData.Email
+	}
+
 	if loginData.Username != "" {
 		user, err = s.userService.GetUserByUsername(ctx, loginData.Username)
 	} else if loginData.Email != "" {
@@ -38,57 +58,147 @@ func (s *AuthService) Login(ctx context.Context, loginData *models.AuthCredentia
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.recordAudit(ctx, models.AuditLoginFailed, 0, device, map[string]interface{}{"identifier": identifier, "reason": "unknown user"})
+			if err := s.recordFailure(ctx, models.LoginLockout, 0, device.IPAddress); err != nil {
+				return nil, nil, err
+			}
 			return nil, nil, errors.New("invalid credentials")
 		}
 		return nil, nil, err
 	}
 
-	if !models.MatchesHash(loginData.Password, user.PasswordHash) {
+	if err := s.checkLockout(ctx, models.LoginLockout, user.ID, device.IPAddress); err != nil {
+		return nil, nil, err
+	}
+
+	matches, err := s.hasher.Verify(loginData.Password, user.PasswordHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !matches {
+		s.recordAudit(ctx, models.AuditLoginFailed, user.ID, device, map[string]interface{}{"identifier": identifier, "reason": "wrong password"})
+		if err := s.recordFailure(ctx, models.LoginLockout, user.ID, device.IPAddress); err != nil {
+			return nil, nil, err
+		}
 		return nil, nil, errors.New("invalid credentials")
 	}
 
+	// The plaintext is only ever in hand here, so this is where old hashes get upgraded
+	if s.hasher.NeedsRehash(user.PasswordHash) {
+		s.rehashPassword(ctx, user, loginData.Password)
+	}
+
 	if !user.EmailVerified {
 		return nil, nil, errors.New("email not verified")
 	}
 
-	tokenVersion := user.TokenVersion
+	if user.TwoFactorEnabled {
+		if loginData.TwoFACode == "" {
+			return nil, nil, models.ErrTwoFactorRequired
+		}
 
-	accessToken, err := utils.GenerateAccessToken(user.ID, user.Roles, tokenVersion, s.config.AccessTokenSecret, s.config.AccessTokenExpiry)
-	if err != nil {
+		ok, err := s.verifyTwoFACode(ctx, user, loginData.TwoFACode, device.IPAddress)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			s.recordAudit(ctx, models.AuditLoginFailed, user.ID, device, map[string]interface{}{"identifier": identifier, "reason": "wrong two-factor code"})
+			return nil, nil, models.ErrInvalidTwoFACode
+		}
+	}
+
+	if err := s.clearFailures(ctx, models.LoginLockout, user.ID); err != nil {
 		return nil, nil, err
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID, tokenVersion, s.config.RefreshTokenSecret, s.config.RefreshTokenExpiry)
+	tokens, err := s.IssueTokens(ctx, user, device)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// Store Refresh Token in the DB
-	expiresAt := time.Now().Add(time.Hour * 24 * time.Duration(s.config.RefreshTokenExpiry))
-	err = s.refreshTokenRepo.Create(ctx, &models.RefreshToken{
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: expiresAt,
-	})
+	s.recordAudit(ctx, models.AuditLoginSucceeded, user.ID, device, nil)
+	return tokens, user, nil
+}
+
+// IssueTokens starts a new session, and with it a new refresh token family, for an authenticated user
+func (s *AuthService) IssueTokens(ctx context.Context, user *models.User, device *models.DeviceInfo) (map[string]string, error) {
+	familyID, err := utils.GenerateRandomToken(16)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	return map[string]string{
-		"access_token":  accessToken,
-		"refresh_token": refreshToken,
-	}, user, nil
+	tokens, record, err := s.newTokenPair(user, familyID, device, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.refreshTokenRepo.Create(ctx, record); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
 }
 
-func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
-	// Remove refresh token from database
-	return s.refreshTokenRepo.Delete(ctx, refreshToken)
+// LoginExternal signs in a user who proved who they are elsewhere, like a social provider. The login
+// lockout still applies, and users with 2FA get a *models.TwoFactorChallengeError instead of tokens.
+func (s *AuthService) LoginExternal(ctx context.Context, user *models.User, device *models.DeviceInfo) (map[string]string, error) {
+	if err := s.checkLockout(ctx, models.LoginLockout, user.ID, device.IPAddress); err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled {
+		challenge, err := s.startTwoFactorChallenge(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &models.TwoFactorChallengeError{Challenge: challenge}
+	}
+
+	return s.IssueTokens(ctx, user, device)
 }
 
-func (s *AuthService) RotateRefreshToken(ctx context.Context, oldRefreshToken string) (map[string]string, error) {
+// Logout ends the whole session rather than just deleting the current token. Older tokens of the
+// family then read as revoked, not as reuse, if they are replayed later.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string, device *models.DeviceInfo) error {
+	stored, err := s.refreshTokenRepo.FindByHash(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := s.revokeSessions(ctx, stored.UserID, []string{stored.FamilyID}); err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditLogout, stored.UserID, device, map[string]interface{}{"session_id": stored.FamilyID})
+	return nil
+}
+
+func (s *AuthService) RotateRefreshToken(ctx context.Context, oldRefreshToken string, device *models.DeviceInfo) (map[string]string, error) {
 	// Verify the refresh token
-	storedToken, err := s.refreshTokenRepo.FindByToken(ctx, oldRefreshToken)
-	if err != nil || storedToken.ExpiresAt.Before(time.Now()) {
+	if _, err := utils.ParseToken(s.keys, oldRefreshToken, utils.RefreshTokenType); err != nil {
+		return nil, errors.New("invalid or expired refresh token")
+	}
+
+	storedToken, err := s.refreshTokenRepo.FindByHash(ctx, utils.HashToken(oldRefreshToken))
+	if err != nil {
+		return nil, errors.New("invalid or expired refresh token")
+	}
+
+	// Revoked sessions are simply over. Treating them as reuse would let anyone holding
+	// the token of a signed out device keep logging the user out everywhere.
+	if storedToken.RevokedAt != nil {
+		return nil, errors.New("invalid or expired refresh token")
+	}
+
+	// A token that was already exchanged is being replayed, assume it was stolen
+	if storedToken.RotatedAt != nil {
+		return nil, s.revokeTokenFamily(ctx, storedToken, device)
+	}
+
+	if storedToken.ExpiresAt.Before(time.Now()) {
 		return nil, errors.New("invalid or expired refresh token")
 	}
 
@@ -97,108 +207,148 @@ func (s *AuthService) RotateRefreshToken(ctx context.Context, oldRefreshToken st
 		return nil, err
 	}
 
-	// Invalidate the old refresh token
-	err = s.refreshTokenRepo.Delete(ctx, oldRefreshToken)
+	tokens, record, err := s.newTokenPair(user, storedToken.FamilyID, device, storedToken.SessionStartedAt)
 	if err != nil {
 		return nil, err
 	}
 
-	// Generate new tokens
+	if err := s.refreshTokenRepo.Rotate(ctx, storedToken, record); err != nil {
+		if errors.Is(err, models.ErrRefreshTokenReused) {
+			return nil, s.revokeTokenFamily(ctx, storedToken, device)
+		}
+		return nil, err
+	}
+
+	s.recordAudit(ctx, models.AuditTokenRotated, user.ID, device, map[string]interface{}{"session_id": storedToken.FamilyID})
+
+	return tokens, nil
+}
+
+// newTokenPair mints an access/refresh pair and the unsaved refresh token record for familyID.
+// The family ID doubles as the session ID carried in the access token's sid claim.
+func (s *AuthService) newTokenPair(user *models.User, familyID string, device *models.DeviceInfo, sessionStartedAt time.Time) (map[string]string, *models.RefreshToken, error) {
 	tokenVersion := user.TokenVersion
-	accessToken, err := utils.GenerateAccessToken(user.ID, user.Roles, tokenVersion, s.config.AccessTokenSecret, s.config.AccessTokenExpiry)
+
+	accessToken, err := utils.GenerateAccessToken(s.keys, user.ID, user.Roles, tokenVersion, familyID, s.config.AccessTokenExpiry)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID, tokenVersion, s.config.RefreshTokenSecret, s.config.RefreshTokenExpiry)
+	refreshToken, err := utils.GenerateRefreshToken(s.keys, user.ID, tokenVersion, s.config.RefreshTokenExpiry)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	expiresAt := time.Now().Add(time.Hour * 24 * time.Duration(s.config.RefreshTokenExpiry))
-	err = s.refreshTokenRepo.Create(ctx, &models.RefreshToken{
+	record := &models.RefreshToken{
 		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: expiresAt,
-	})
-	if err != nil {
-		return nil, err
+		TokenHash: utils.HashToken(refreshToken),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(time.Hour * 24 * time.Duration(s.config.RefreshTokenExpiry)),
+
+		UserAgent:        device.UserAgent,
+		IPAddress:        device.IPAddress,
+		SessionStartedAt: sessionStartedAt,
+		LastUsedAt:       time.Now(),
 	}
 
 	return map[string]string{
 		"access_token":  accessToken,
 		"refresh_token": refreshToken,
-	}, nil
+	}, record, nil
+}
+
+// revokeTokenFamily kills every session descending from the reused token and bumps the
+// user's token version so outstanding access tokens stop working too
+func (s *AuthService) revokeTokenFamily(ctx context.Context, reused *models.RefreshToken, device *models.DeviceInfo) error {
+	log.Warnf("Refresh token reuse detected for user %d, revoking family %s", reused.UserID, reused.FamilyID)
+
+	if err := s.refreshTokenRepo.RevokeFamily(ctx, reused.FamilyID); err != nil {
+		return err
+	}
+	if err := s.userService.IncrementTokenVersion(ctx, reused.UserID); err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditRefreshTokenReused, reused.UserID, device, map[string]interface{}{"session_id": reused.FamilyID})
+	return models.ErrRefreshTokenReused
+}
+
+// LogoutAll bumps the token version, which AuthProtected checks, drops every refresh token
+// and revokes the user's API keys. Password resets and changes go through here too.
+func (s *AuthService) LogoutAll(ctx context.Context, userID uint) error {
+	if err := s.userService.IncrementTokenVersion(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.refreshTokenRepo.InvalidateOldTokens(ctx, userID); err != nil {
+		return err
+	}
+	return s.apiKeyRepo.RevokeAllForUser(ctx, userID)
 }
 
 // Blacklist access tokens in Redis
-func (s *AuthService) BlacklistAccessToken(ctx context.Context, accessToken string, expiry time.Duration) error {
-	return s.redisClient.Set(ctx, accessToken, "blacklisted", expiry).Err()
+func (s *AuthService) BlacklistAccessToken(ctx context.Context, jti string, expiry time.Duration) error {
+	return s.redisClient.Set(ctx, utils.AccessTokenBlacklistKey(jti), "blacklisted", expiry).Err()
 }
 
-func (s *AuthService) Register(ctx context.Context, registerData *models.AuthCredentials) (string, *models.User, error) {
+// RevokeAccessToken blacklists a still-valid access token until it would have expired anyway
+func (s *AuthService) RevokeAccessToken(ctx context.Context, accessToken string) error {
+	claims, err := utils.ParseToken(s.keys, accessToken, utils.AccessTokenType)
+	if err != nil {
+		// Expired or invalid tokens are already unusable
+		return nil
+	}
+
+	jti, _ := claims["jti"].(string)
+	exp, err := claims.GetExpirationTime()
+	if jti == "" || err != nil || exp == nil {
+		return nil
+	}
+
+	return s.BlacklistAccessToken(ctx, jti, time.Until(exp.Time))
+}
+
+func (s *AuthService) Register(ctx context.Context, registerData *models.AuthCredentials, device *models.DeviceInfo) (map[string]string, *models.User, error) {
 	if !models.IsValidEmail(registerData.Email) {
-		return "", nil, fmt.Errorf("please, provide a valid email to register")
+		return nil, nil, fmt.Errorf("please, provide a valid email to register")
 	}
 
 	if _, err := s.repository.GetUser(ctx, "email = ?", registerData.Email); !errors.Is(err, gorm.ErrRecordNotFound) {
-		return "", nil, fmt.Errorf("the user email is already in use")
+		return nil, nil, fmt.Errorf("the user email is already in use")
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(registerData.Password), bcrypt.DefaultCost)
+	if err := s.passwordPolicy.Validate(registerData.Password, registerData.Username, registerData.Email); err != nil {
+		return nil, nil, err
+	}
+
+	hashedPassword, err := s.hasher.Hash(registerData.Password)
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
-	registerData.Password = string(hashedPassword)
+	registerData.Password = hashedPassword
 
 	user, err := s.repository.RegisterUser(ctx, registerData)
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
-	claims := jwt.MapClaims{
-		"id":   user.ID,
-		"role": user.Roles,
-		"exp":  time.Now().Add(time.Hour * 168).Unix(),
+	// A failed delivery should not fail registration, the user can ask for a resend
+	if err := s.SendVerificationEmail(ctx, user); err != nil {
+		log.Errorf("Unable to send verification email to user %d: %v", user.ID, err)
 	}
 
-	token, err := utils.GenerateJWT(claims, jwt.SigningMethodHS256, os.Getenv("JWT_SECRET"))
+	tokens, err := s.IssueTokens(ctx, user, device)
 	if err != nil {
-		return "", nil, err
+		return nil, nil, err
 	}
 
-	return token, user, nil
-}
-
-func (s *AuthService) EnableTwoFactor(ctx context.Context, userID uint) error {
-	return s.repository.VerifyPhoneNumber(ctx, userID, "123456") // TODO Replace with actual logic to verify phone number
-}
-
-func (s *AuthService) VerifyEmail(ctx context.Context, userID uint) error {
-	return s.repository.VerifyEmail(ctx, userID)
-}
-
-func (s *AuthService) VerifyPhoneNumber(ctx context.Context, userID uint, code string) error {
-	return s.repository.VerifyPhoneNumber(ctx, userID, code)
-}
-
-// IsValidTwoFACode checks if the provided 2FA code is valid
-func (s *AuthService) IsValidTwoFACode(twoFACode string) bool {
-	// Here we could verify the 2FA code, for example, with a service like Google Authenticator
-	// For this example, we'll assume that "654321" is a valid code
-	return twoFACode == "654321"
+	return tokens, user, nil
 }
 
 func (s *AuthService) GetUserDataFromToken(ctx context.Context, token string) (*models.User, error) {
-	parsedToken, err := utils.ParseToken(token, s.config.AccessTokenSecret)
-	if err != nil || !parsedToken.Valid {
+	claims, err := utils.ParseToken(s.keys, token, utils.AccessTokenType)
+	if err != nil {
 		return nil, errors.New("invalid token")
 	}
 
-	claims, ok := parsedToken.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, errors.New("could not parse token claims")
-	}
-
 	userID, ok := claims["id"].(float64)
 	if !ok {
 		return nil, errors.New("invalid token claims")
@@ -210,21 +360,75 @@ func (s *AuthService) GetUserDataFromToken(ctx context.Context, token string) (*
 		return nil, errors.New("user not found")
 	}
 
+	if adminID, _, ok := utils.Impersonator(claims); ok {
+		user.ImpersonatedBy = &adminID
+	}
+
 	return user, nil
 }
 
+// recordAudit logs an event the user performed on their own account
+func (s *AuthService) recordAudit(ctx context.Context, eventType models.AuditEventType, userID uint, device *models.DeviceInfo, metadata map[string]interface{}) {
+	entry := &models.AuditLog{Type: eventType, Metadata: metadata}
+	if userID != 0 {
+		entry.ActorID = &userID
+		entry.TargetID = &userID
+	}
+	if device != nil {
+		entry.IPAddress = device.IPAddress
+		entry.UserAgent = device.UserAgent
+	}
+	s.audit.Record(ctx, entry)
+}
+
+// emailKey identifies an address in rate limit keys without storing it, whether or not it has an account
+func emailKey(email string) string {
+	return utils.HashToken(strings.ToLower(strings.TrimSpace(email)))
+}
+
+// cooldown fails with models.ErrTooManyRequests if key was already set within d
+func (s *AuthService) cooldown(ctx context.Context, key string, d time.Duration) error {
+	ok, err := s.redisClient.SetNX(ctx, key, 1, d).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return models.ErrTooManyRequests
+	}
+	return nil
+}
+
 func NewAuthService(
 	repository models.AuthRepository,
 	userService models.UserService,
 	config config.EnvConfig,
 	refreshTokenRepo models.RefreshTokenRepository,
+	recoveryCodeRepo models.RecoveryCodeRepository,
+	apiKeyRepo models.APIKeyRepository,
+	verificationTokenRepo models.VerificationTokenRepository,
+	mailer models.Mailer,
+	smsSender models.SMSSender,
 	redisClient *redis.Client,
+	keys *signing.KeyManager,
+	hasher models.PasswordHasher,
+	passwordPolicy models.PasswordPolicy,
+	audit models.AuditService,
 ) models.AuthService {
 	return &AuthService{
 		repository:       repository,
 		userService:      userService,
 		config:           &config,
 		refreshTokenRepo: refreshTokenRepo,
+		recoveryCodeRepo: recoveryCodeRepo,
+		apiKeyRepo:       apiKeyRepo,
 		redisClient:      redisClient,
+		keys:             keys,
+		hasher:           hasher,
+		passwordPolicy:   passwordPolicy,
+		audit:            audit,
+
+		verificationTokenRepo: verificationTokenRepo,
+		mailer:                mailer,
+		smsSender:             smsSender,
 	}
 }
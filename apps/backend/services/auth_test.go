@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/montekkundan/bored/backend/config"
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/signing"
+	"github.com/montekkundan/bored/backend/utils"
+	"gorm.io/gorm"
+)
+
+// memoryRefreshTokens mirrors the conditional updates of repositories.RefreshTokenRepository
+type memoryRefreshTokens struct {
+	models.RefreshTokenRepository
+
+	mu     sync.Mutex
+	nextID uint
+	tokens map[string]*models.RefreshToken
+
+	// beforeRotate runs between the service reading a token and rotating it
+	beforeRotate func()
+}
+
+func (r *memoryRefreshTokens) Create(ctx context.Context, token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	token.ID = r.nextID
+	stored := *token
+	r.tokens[token.TokenHash] = &stored
+	return nil
+}
+
+func (r *memoryRefreshTokens) FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *token
+	return &found, nil
+}
+
+func (r *memoryRefreshTokens) Rotate(ctx context.Context, current *models.RefreshToken, next *models.RefreshToken) error {
+	if r.beforeRotate != nil {
+		r.beforeRotate()
+	}
+
+	r.mu.Lock()
+	stored := r.tokens[current.TokenHash]
+	if stored.RotatedAt != nil || stored.RevokedAt != nil {
+		r.mu.Unlock()
+		return models.ErrRefreshTokenReused
+	}
+	now := time.Now()
+	stored.RotatedAt = &now
+	r.mu.Unlock()
+
+	return r.Create(ctx, next)
+}
+
+func (r *memoryRefreshTokens) RevokeFamily(ctx context.Context, familyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *memoryRefreshTokens) get(t *testing.T, rawToken string) *models.RefreshToken {
+	t.Helper()
+	token, err := r.FindByHash(context.Background(), utils.HashToken(rawToken))
+	if err != nil {
+		t.Fatalf("refresh token not stored: %v", err)
+	}
+	return token
+}
+
+type memoryUsers struct {
+	models.UserService
+
+	mu    sync.Mutex
+	users map[uint]*models.User
+}
+
+func (s *memoryUsers) GetUserByID(ctx context.Context, userID uint) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *user
+	return &found, nil
+}
+
+func (s *memoryUsers) IncrementTokenVersion(ctx context.Context, userID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[userID].TokenVersion++
+	return nil
+}
+
+func (s *memoryUsers) tokenVersion(userID uint) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.users[userID].TokenVersion
+}
+
+type memoryAudit struct {
+	models.AuditService
+
+	mu      sync.Mutex
+	entries []*models.AuditLog
+}
+
+func (a *memoryAudit) Record(ctx context.Context, entry *models.AuditLog) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = append(a.entries, entry)
+}
+
+func (a *memoryAudit) count(eventType models.AuditEventType) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := 0
+	for _, entry := range a.entries {
+		if entry.Type == eventType {
+			n++
+		}
+	}
+	return n
+}
+
+type rotationFixture struct {
+	service *AuthService
+	tokens  *memoryRefreshTokens
+	users   *memoryUsers
+	audit   *memoryAudit
+	user    *models.User
+	device  *models.DeviceInfo
+}
+
+func newRotationFixture(t *testing.T) *rotationFixture {
+	t.Helper()
+	keys, err := signing.LoadKeyManager("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &models.User{Username: "jane", Email: "jane@example.com"}
+	user.ID = 1
+
+	f := &rotationFixture{
+		tokens: &memoryRefreshTokens{tokens: map[string]*models.RefreshToken{}},
+		users:  &memoryUsers{users: map[uint]*models.User{user.ID: user}},
+		audit:  &memoryAudit{},
+		user:   user,
+		device: &models.DeviceInfo{UserAgent: "test", IPAddress: "203.0.113.7"},
+	}
+	f.service = &AuthService{
+		userService:      f.users,
+		refreshTokenRepo: f.tokens,
+		config:           &config.EnvConfig{AccessTokenExpiry: 15, RefreshTokenExpiry: 7},
+		keys:             keys,
+		audit:            f.audit,
+	}
+	return f
+}
+
+// login starts a session and returns its refresh token
+func (f *rotationFixture) login(t *testing.T) string {
+	t.Helper()
+	tokens, err := f.service.IssueTokens(context.Background(), f.user, f.device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens["refresh_token"]
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	f := newRotationFixture(t)
+	first := f.login(t)
+
+	tokens, err := f.service.RotateRefreshToken(context.Background(), first, f.device)
+	if err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+	second := tokens["refresh_token"]
+	if second == "" || second == first || tokens["access_token"] == "" {
+		t.Fatalf("RotateRefreshToken returned %v", tokens)
+	}
+
+	old, next := f.tokens.get(t, first), f.tokens.get(t, second)
+	if old.RotatedAt == nil || old.RevokedAt != nil {
+		t.Errorf("old token rotated_at = %v, revoked_at = %v, want it only rotated", old.RotatedAt, old.RevokedAt)
+	}
+	if next.RotatedAt != nil || next.RevokedAt != nil {
+		t.Error("new token is not live")
+	}
+	if next.FamilyID != old.FamilyID || !next.SessionStartedAt.Equal(old.SessionStartedAt) {
+		t.Error("new token does not continue the session")
+	}
+
+	if v := f.users.tokenVersion(f.user.ID); v != 0 {
+		t.Errorf("token version = %d, want 0", v)
+	}
+	if n := f.audit.count(models.AuditTokenRotated); n != 1 {
+		t.Errorf("recorded %d rotations, want 1", n)
+	}
+
+	// The new token keeps the session going
+	if _, err := f.service.RotateRefreshToken(context.Background(), second, f.device); err != nil {
+		t.Errorf("rotating the new token: %v", err)
+	}
+}
+
+func TestRotatedRefreshTokenReplay(t *testing.T) {
+	f := newRotationFixture(t)
+	first := f.login(t)
+	other := f.login(t)
+
+	tokens, err := f.service.RotateRefreshToken(context.Background(), first, f.device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := tokens["refresh_token"]
+
+	if _, err := f.service.RotateRefreshToken(context.Background(), first, f.device); !errors.Is(err, models.ErrRefreshTokenReused) {
+		t.Fatalf("replaying a rotated token error = %v, want ErrRefreshTokenReused", err)
+	}
+
+	for name, raw := range map[string]string{"replayed": first, "current": second} {
+		if f.tokens.get(t, raw).RevokedAt == nil {
+			t.Errorf("%s token of the family is not revoked", name)
+		}
+	}
+	if f.tokens.get(t, other).RevokedAt != nil {
+		t.Error("a session outside the reused family was revoked")
+	}
+	if v := f.users.tokenVersion(f.user.ID); v != 1 {
+		t.Errorf("token version = %d, want 1", v)
+	}
+	if n := f.audit.count(models.AuditRefreshTokenReused); n != 1 {
+		t.Errorf("recorded %d reuses, want 1", n)
+	}
+
+	// The thief's copy of the newer token is dead as well, without counting as another reuse
+	if _, err := f.service.RotateRefreshToken(context.Background(), second, f.device); err == nil || errors.Is(err, models.ErrRefreshTokenReused) {
+		t.Errorf("rotating the revoked head error = %v, want invalid token", err)
+	}
+	if v := f.users.tokenVersion(f.user.ID); v != 1 {
+		t.Errorf("token version = %d after rotating the revoked head, want 1", v)
+	}
+}
+
+func TestRevokedRefreshTokenReplay(t *testing.T) {
+	f := newRotationFixture(t)
+	first := f.login(t)
+
+	tokens, err := f.service.RotateRefreshToken(context.Background(), first, f.device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Signing out revokes the whole family
+	if err := f.tokens.RevokeFamily(context.Background(), f.tokens.get(t, first).FamilyID); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, raw := range map[string]string{"rotated": first, "current": tokens["refresh_token"]} {
+		t.Run(name, func(t *testing.T) {
+			_, err := f.service.RotateRefreshToken(context.Background(), raw, f.device)
+			if err == nil || errors.Is(err, models.ErrRefreshTokenReused) {
+				t.Errorf("RotateRefreshToken error = %v, want invalid token", err)
+			}
+		})
+	}
+
+	if v := f.users.tokenVersion(f.user.ID); v != 0 {
+		t.Errorf("token version = %d, want 0, a signed out token must not log the user out everywhere", v)
+	}
+	if n := f.audit.count(models.AuditRefreshTokenReused); n != 0 {
+		t.Errorf("recorded %d reuses, want 0", n)
+	}
+}
+
+func TestRefreshTokenRotationRace(t *testing.T) {
+	f := newRotationFixture(t)
+	first := f.login(t)
+
+	// Another request exchanges the same token after this one has read it
+	f.tokens.beforeRotate = func() {
+		f.tokens.beforeRotate = nil
+		if _, err := f.service.RotateRefreshToken(context.Background(), first, f.device); err != nil {
+			t.Fatalf("concurrent rotation: %v", err)
+		}
+	}
+
+	if _, err := f.service.RotateRefreshToken(context.Background(), first, f.device); !errors.Is(err, models.ErrRefreshTokenReused) {
+		t.Fatalf("losing the race error = %v, want ErrRefreshTokenReused", err)
+	}
+	if f.tokens.get(t, first).RevokedAt == nil {
+		t.Error("family was not revoked")
+	}
+	if v := f.users.tokenVersion(f.user.ID); v != 1 {
+		t.Errorf("token version = %d, want 1", v)
+	}
+}
+
+func TestRotateRefreshTokenRejects(t *testing.T) {
+	f := newRotationFixture(t)
+
+	expired := f.login(t)
+	f.tokens.mu.Lock()
+	f.tokens.tokens[utils.HashToken(expired)].ExpiresAt = time.Now().Add(-time.Minute)
+	f.tokens.mu.Unlock()
+
+	unknown, err := utils.GenerateRefreshToken(f.service.keys, f.user.ID, 0, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", expired},
+		{"not stored", unknown},
+		{"malformed", "not-a-jwt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.service.RotateRefreshToken(context.Background(), tt.token, f.device); err == nil {
+				t.Error("RotateRefreshToken succeeded")
+			}
+		})
+	}
+
+	if v := f.users.tokenVersion(f.user.ID); v != 0 {
+		t.Errorf("token version = %d, want 0", v)
+	}
+}
@@ -2,52 +2,305 @@ package services
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/lib/pq"
 	"github.com/montekkundan/bored/backend/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultDirectoryPageSize = 20
+	maxDirectoryPageSize     = 100
 )
 
 type BoringSpaceService struct {
 	repository models.BoringSpaceRepository
+	moderation models.BoringSpaceModerationRepository
 }
 
-func NewBoringSpaceService(repository models.BoringSpaceRepository) models.BoringSpaceService {
+func NewBoringSpaceService(repository models.BoringSpaceRepository, moderation models.BoringSpaceModerationRepository) models.BoringSpaceService {
 	return &BoringSpaceService{
 		repository: repository,
+		moderation: moderation,
 	}
 }
 
+// CreateBoringSpace creates the space with its creator as the first admin
 func (s *BoringSpaceService) CreateBoringSpace(ctx context.Context, space *models.BoringSpace) error {
-	// Start a transaction to ensure atomicity
-	txErr := s.repository.CreateBoringSpace(ctx, space)
-	if txErr != nil {
-		return txErr
+	return s.repository.CreateBoringSpace(ctx, space)
+}
+
+func (s *BoringSpaceService) UpdateBoringSpace(ctx context.Context, spaceID uint, req *models.UpdateBoringSpaceRequest) (*models.BoringSpace, error) {
+	space, err := s.repository.GetBoringSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
 	}
 
-	// Add the creator as an admin member
-	member := &models.BoringSpaceMember{
-		BoringSpaceID: space.ID,
-		UserID:        space.CreatorID,
-		Role:          models.BSAdmin,
+	if req.Name != nil {
+		space.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		space.Description = *req.Description
+	}
+	if req.Visibility != nil {
+		space.Visibility = *req.Visibility
 	}
-	return s.repository.AddMember(ctx, member)
+	space.UpdatedAt = time.Now()
+
+	if err := s.repository.UpdateBoringSpace(ctx, space); err != nil {
+		return nil, err
+	}
+	return space, nil
+}
+
+func (s *BoringSpaceService) DeleteBoringSpace(ctx context.Context, spaceID uint) error {
+	return s.repository.DeleteBoringSpace(ctx, spaceID)
+}
+
+func (s *BoringSpaceService) TransferOwnership(ctx context.Context, spaceID uint, newOwnerID uint) error {
+	return s.repository.TransferOwnership(ctx, spaceID, newOwnerID)
+}
+
+// LeaveBoringSpace removes userID from the space. The owner has to hand the space over first.
+func (s *BoringSpaceService) LeaveBoringSpace(ctx context.Context, spaceID uint, userID uint) error {
+	if err := s.ensureNotOwner(ctx, spaceID, userID); err != nil {
+		return err
+	}
+	return s.repository.RemoveMember(ctx, spaceID, userID)
+}
+
+// ensureNotOwner fails with models.ErrSpaceOwner if userID owns the space
+func (s *BoringSpaceService) ensureNotOwner(ctx context.Context, spaceID uint, userID uint) error {
+	space, err := s.repository.GetBoringSpaceByID(ctx, spaceID)
+	if err != nil {
+		return err
+	}
+	if space.CreatorID == userID {
+		return models.ErrSpaceOwner
+	}
+	return nil
 }
 
 func (s *BoringSpaceService) GetBoringSpaceByID(ctx context.Context, spaceID uint) (*models.BoringSpace, error) {
 	return s.repository.GetBoringSpaceByID(ctx, spaceID)
 }
 
-func (s *BoringSpaceService) AddMember(ctx context.Context, member *models.BoringSpaceMember) error {
-	return s.repository.AddMember(ctx, member)
+func (s *BoringSpaceService) CanView(ctx context.Context, spaceID uint, userID uint) (bool, error) {
+	visibility, err := s.repository.GetVisibility(ctx, spaceID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if visibility != models.SpacePrivate {
+		return true, nil
+	}
+
+	if _, err := s.repository.GetMember(ctx, spaceID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
 }
 
-func (s *BoringSpaceService) RemoveMember(ctx context.Context, spaceID uint, userID uint) error {
-	return s.repository.RemoveMember(ctx, spaceID, userID)
+func (s *BoringSpaceService) Directory(ctx context.Context, filter *models.BoringSpaceFilter) ([]*models.BoringSpace, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultDirectoryPageSize
+	}
+	if filter.Limit > maxDirectoryPageSize {
+		filter.Limit = maxDirectoryPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	filter.Query = strings.TrimSpace(filter.Query)
+	return s.repository.ListPublic(ctx, filter)
+}
+
+// TouchActivity bumps the space up the directory's "activity" sort
+func (s *BoringSpaceService) TouchActivity(ctx context.Context, spaceID uint) error {
+	return s.repository.TouchActivity(ctx, spaceID)
+}
+
+// RemoveMember kicks userID out of the space, noting it in the space's moderation log
+func (s *BoringSpaceService) RemoveMember(ctx context.Context, spaceID uint, userID uint, moderatorID uint) error {
+	if err := s.ensureNotOwner(ctx, spaceID, userID); err != nil {
+		return err
+	}
+	if err := s.repository.RemoveMember(ctx, spaceID, userID); err != nil {
+		return err
+	}
+
+	entry := &models.BoringSpaceModerationLog{
+		BoringSpaceID: spaceID,
+		ModeratorID:   moderatorID,
+		TargetUserID:  userID,
+		Action:        models.SpaceActionKick,
+	}
+	if err := s.moderation.CreateLogEntry(ctx, entry); err != nil {
+		log.Errorf("Unable to log kick of user %d from BoringSpace %d: %v", userID, spaceID, err)
+	}
+	return nil
 }
 
 func (s *BoringSpaceService) GetMembers(ctx context.Context, spaceID uint) ([]*models.BoringSpaceMember, error) {
 	return s.repository.GetMembers(ctx, spaceID)
 }
 
+func (s *BoringSpaceService) GetMember(ctx context.Context, spaceID uint, userID uint) (*models.BoringSpaceMember, error) {
+	return s.repository.GetMember(ctx, spaceID, userID)
+}
+
 func (s *BoringSpaceService) UpdateMemberRole(ctx context.Context, spaceID uint, userID uint, role models.BoringSpaceRole) error {
+	if role != models.BSAdmin {
+		if err := s.ensureNotOwner(ctx, spaceID, userID); err != nil {
+			return err
+		}
+	}
 	return s.repository.UpdateMemberRole(ctx, spaceID, userID, role)
 }
+
+// GetRoles returns the effective permissions of every role in the space, built-in roles first
+func (s *BoringSpaceService) GetRoles(ctx context.Context, spaceID uint) ([]*models.BoringSpaceRoleDefinition, error) {
+	stored, err := s.repository.GetRoleDefinitions(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[models.BoringSpaceRole]*models.BoringSpaceRoleDefinition, len(stored))
+	for _, definition := range stored {
+		overrides[definition.Name] = definition
+	}
+
+	roles := make([]*models.BoringSpaceRoleDefinition, 0, len(stored)+4)
+	for _, name := range []models.BoringSpaceRole{models.BSAdmin, models.BSModerator, models.BSMember, models.BSViewer} {
+		definition, ok := overrides[name]
+		if !ok || name == models.BSAdmin {
+			definition = defaultRoleDefinition(spaceID, name)
+		}
+		definition.Builtin = true
+		roles = append(roles, definition)
+	}
+	for _, definition := range stored {
+		if !definition.Name.IsBuiltin() {
+			roles = append(roles, definition)
+		}
+	}
+	return roles, nil
+}
+
+// SaveRole overrides a built-in role's permissions or creates or updates a custom role
+func (s *BoringSpaceService) SaveRole(ctx context.Context, spaceID uint, name models.BoringSpaceRole, permissions []models.BoringSpacePermission) (*models.BoringSpaceRoleDefinition, error) {
+	if name == models.BSAdmin {
+		return nil, models.ErrSpaceAdminRoleFixed
+	}
+	if !name.IsValidName() {
+		return nil, models.ErrInvalidSpaceRoleName
+	}
+
+	perms := pq.StringArray{}
+	for _, perm := range permissions {
+		if !perm.IsValid() {
+			return nil, models.ErrInvalidSpacePermission
+		}
+		perms = append(perms, string(perm))
+	}
+
+	definition := &models.BoringSpaceRoleDefinition{
+		BoringSpaceID: spaceID,
+		Name:          name,
+		Permissions:   perms,
+		Builtin:       name.IsBuiltin(),
+	}
+	if err := s.repository.SaveRoleDefinition(ctx, definition); err != nil {
+		return nil, err
+	}
+	return definition, nil
+}
+
+// DeleteRole resets a built-in role to its defaults or removes a custom role nobody holds
+func (s *BoringSpaceService) DeleteRole(ctx context.Context, spaceID uint, name models.BoringSpaceRole) error {
+	if name == models.BSAdmin {
+		return models.ErrSpaceAdminRoleFixed
+	}
+
+	if !name.IsBuiltin() {
+		if _, err := s.repository.GetRoleDefinition(ctx, spaceID, name); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return models.ErrUnknownSpaceRole
+			}
+			return err
+		}
+
+		holders, err := s.repository.CountMembersWithRole(ctx, spaceID, name)
+		if err != nil {
+			return err
+		}
+		if holders > 0 {
+			return models.ErrSpaceRoleInUse
+		}
+	}
+
+	return s.repository.DeleteRoleDefinition(ctx, spaceID, name)
+}
+
+// ValidateRole fails with models.ErrUnknownSpaceRole unless role is built-in or defined in the space
+func (s *BoringSpaceService) ValidateRole(ctx context.Context, spaceID uint, role models.BoringSpaceRole) error {
+	if role.IsBuiltin() {
+		return nil
+	}
+
+	if _, err := s.repository.GetRoleDefinition(ctx, spaceID, role); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.ErrUnknownSpaceRole
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *BoringSpaceService) HasPermission(ctx context.Context, member *models.BoringSpaceMember, perm models.BoringSpacePermission) (bool, error) {
+	// Mutes and timeouts apply to every role, admins included
+	sanctions, err := s.moderation.GetActiveSanctions(ctx, member.BoringSpaceID, member.UserID)
+	if err != nil {
+		return false, err
+	}
+	for _, sanction := range sanctions {
+		if sanction.Type.Blocks(perm) {
+			return false, nil
+		}
+	}
+
+	if member.Role == models.BSAdmin {
+		return true, nil
+	}
+
+	definition, err := s.repository.GetRoleDefinition(ctx, member.BoringSpaceID, member.Role)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, err
+		}
+		// Custom roles that were never defined grant nothing
+		definition = defaultRoleDefinition(member.BoringSpaceID, member.Role)
+	}
+	return definition.HasPermission(perm), nil
+}
+
+func defaultRoleDefinition(spaceID uint, name models.BoringSpaceRole) *models.BoringSpaceRoleDefinition {
+	perms := pq.StringArray{}
+	for _, perm := range models.DefaultSpacePermissions[name] {
+		perms = append(perms, string(perm))
+	}
+	return &models.BoringSpaceRoleDefinition{
+		BoringSpaceID: spaceID,
+		Name:          name,
+		Permissions:   perms,
+	}
+}
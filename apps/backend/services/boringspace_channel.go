@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/montekkundan/bored/backend/models"
+	"gorm.io/gorm"
+)
+
+type BoringSpaceChannelService struct {
+	chats  models.ChatRepository
+	spaces models.BoringSpaceService
+}
+
+func NewBoringSpaceChannelService(chats models.ChatRepository, spaces models.BoringSpaceService) models.BoringSpaceChannelService {
+	return &BoringSpaceChannelService{
+		chats:  chats,
+		spaces: spaces,
+	}
+}
+
+func (s *BoringSpaceChannelService) CreateChannel(ctx context.Context, spaceID uint, req *models.CreateChannelRequest) (*models.Chat, error) {
+	minRole := req.MinRole
+	if minRole == "" {
+		minRole = models.BSViewer
+	}
+	// Custom roles have no rank of their own to gate on
+	if !minRole.IsBuiltin() {
+		return nil, models.ErrUnknownSpaceRole
+	}
+
+	channel := &models.Chat{
+		Name:          strings.TrimSpace(req.Name),
+		IsGroup:       true,
+		BoringSpaceID: &spaceID,
+		MinRole:       minRole,
+		Announcement:  req.Announcement,
+	}
+	if err := s.chats.CreateChat(ctx, channel); err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+// GetChannels lists the channels of the member's space that their role can see
+func (s *BoringSpaceChannelService) GetChannels(ctx context.Context, member *models.BoringSpaceMember, includeArchived bool) ([]*models.Chat, error) {
+	chats, err := s.chats.GetSpaceChats(ctx, member.BoringSpaceID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+
+	channels := make([]*models.Chat, 0, len(chats))
+	for _, chat := range chats {
+		if chat.VisibleTo(member) {
+			channels = append(channels, chat)
+		}
+	}
+	return channels, nil
+}
+
+func (s *BoringSpaceChannelService) ArchiveChannel(ctx context.Context, spaceID uint, chatID uint) error {
+	if _, err := s.channel(ctx, spaceID, chatID); err != nil {
+		return err
+	}
+	return s.chats.ArchiveChat(ctx, chatID)
+}
+
+func (s *BoringSpaceChannelService) GetMessages(ctx context.Context, member *models.BoringSpaceMember, chatID uint, page *models.PageRequest) ([]*models.Message, string, error) {
+	channel, err := s.channel(ctx, member.BoringSpaceID, chatID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !channel.VisibleTo(member) {
+		return nil, "", gorm.ErrRecordNotFound
+	}
+	return s.chats.GetMessages(ctx, chatID, page)
+}
+
+// SendMessage needs the post permission, and a moderator or admin in announcement channels
+func (s *BoringSpaceChannelService) SendMessage(ctx context.Context, member *models.BoringSpaceMember, chatID uint, content string) (*models.Message, error) {
+	channel, err := s.channel(ctx, member.BoringSpaceID, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if !channel.VisibleTo(member) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if channel.ArchivedAt != nil {
+		return nil, models.ErrChannelArchived
+	}
+
+	canPost, err := s.spaces.HasPermission(ctx, member, models.SpacePermPost)
+	if err != nil {
+		return nil, err
+	}
+	if !canPost || (channel.Announcement && !member.Role.AtLeast(models.BSModerator)) {
+		return nil, models.ErrChannelReadOnly
+	}
+
+	message := &models.Message{
+		ChatID:   chatID,
+		SenderID: member.UserID,
+		Content:  content,
+	}
+	if err := s.chats.SendMessage(ctx, message); err != nil {
+		return nil, err
+	}
+
+	if err := s.spaces.TouchActivity(ctx, member.BoringSpaceID); err != nil {
+		log.Errorf("Unable to update activity of BoringSpace %d: %v", member.BoringSpaceID, err)
+	}
+	return message, nil
+}
+
+// channel loads a chat, treating chats outside the space as missing
+func (s *BoringSpaceChannelService) channel(ctx context.Context, spaceID uint, chatID uint) (*models.Chat, error) {
+	chat, err := s.chats.GetChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.BoringSpaceID == nil || *chat.BoringSpaceID != spaceID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return chat, nil
+}
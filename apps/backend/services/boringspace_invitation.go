@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/utils"
+	"gorm.io/gorm"
+)
+
+type BoringSpaceInvitationService struct {
+	repository    models.BoringSpaceInvitationRepository
+	spaces        models.BoringSpaceRepository
+	moderation    models.BoringSpaceModerationRepository
+	users         models.UserService
+	notifications models.NotificationService
+}
+
+func NewBoringSpaceInvitationService(repository models.BoringSpaceInvitationRepository, spaces models.BoringSpaceRepository, moderation models.BoringSpaceModerationRepository, users models.UserService, notifications models.NotificationService) models.BoringSpaceInvitationService {
+	return &BoringSpaceInvitationService{
+		repository:    repository,
+		spaces:        spaces,
+		moderation:    moderation,
+		users:         users,
+		notifications: notifications,
+	}
+}
+
+func (s *BoringSpaceInvitationService) Invite(ctx context.Context, spaceID uint, inviterID uint, req *models.CreateInvitationRequest) (*models.BoringSpaceInvitation, error) {
+	space, err := s.spaces.GetBoringSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.users.GetUserByID(ctx, req.UserID); err != nil {
+		return nil, err
+	}
+	if err := s.ensureCanJoin(ctx, spaceID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repository.GetPendingInvitation(ctx, spaceID, req.UserID); err == nil {
+		return nil, models.ErrAlreadyInvited
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	invitation := &models.BoringSpaceInvitation{
+		BoringSpaceID: spaceID,
+		InviterID:     inviterID,
+		InviteeID:     req.UserID,
+		Role:          defaultSpaceRole(req.Role),
+		Status:        models.InvitationPending,
+	}
+	if err := s.repository.CreateInvitation(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	s.notify(ctx, req.UserID, fmt.Sprintf("You've been invited to join %s", space.Name))
+	return invitation, nil
+}
+
+func (s *BoringSpaceInvitationService) GetSpaceInvitations(ctx context.Context, spaceID uint) ([]*models.BoringSpaceInvitation, error) {
+	return s.repository.GetSpaceInvitations(ctx, spaceID)
+}
+
+func (s *BoringSpaceInvitationService) GetUserInvitations(ctx context.Context, userID uint) ([]*models.BoringSpaceInvitation, error) {
+	return s.repository.GetUserInvitations(ctx, userID)
+}
+
+// RespondToInvitation accepts or declines an invitation addressed to userID
+func (s *BoringSpaceInvitationService) RespondToInvitation(ctx context.Context, invitationID uint, userID uint, accept bool) (*models.BoringSpaceInvitation, error) {
+	invitation, err := s.repository.GetInvitation(ctx, invitationID)
+	if err != nil {
+		return nil, err
+	}
+	// Someone else's invitation doesn't exist as far as userID is concerned
+	if invitation.InviteeID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	status := models.InvitationDeclined
+	var member *models.BoringSpaceMember
+	if accept {
+		if err := s.ensureCanJoin(ctx, invitation.BoringSpaceID, userID); err != nil {
+			return nil, err
+		}
+		status = models.InvitationAccepted
+		member = &models.BoringSpaceMember{
+			BoringSpaceID: invitation.BoringSpaceID,
+			UserID:        userID,
+			Role:          invitation.Role,
+		}
+	}
+
+	if err := s.repository.CloseInvitation(ctx, invitation.ID, status, member); err != nil {
+		return nil, err
+	}
+	invitation.Status = status
+	if member != nil {
+		s.touchActivity(ctx, member.BoringSpaceID)
+	}
+
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err == nil && invitation.BoringSpace != nil {
+		s.notify(ctx, invitation.InviterID, fmt.Sprintf("%s %s your invitation to %s", user.Username, status, invitation.BoringSpace.Name))
+	}
+	return invitation, nil
+}
+
+func (s *BoringSpaceInvitationService) RevokeInvitation(ctx context.Context, spaceID uint, invitationID uint) error {
+	invitation, err := s.repository.GetInvitation(ctx, invitationID)
+	if err != nil {
+		return err
+	}
+	if invitation.BoringSpaceID != spaceID {
+		return gorm.ErrRecordNotFound
+	}
+	return s.repository.CloseInvitation(ctx, invitationID, models.InvitationRevoked, nil)
+}
+
+func (s *BoringSpaceInvitationService) CreateInviteCode(ctx context.Context, spaceID uint, creatorID uint, req *models.CreateInviteCodeRequest) (*models.BoringSpaceInviteCode, error) {
+	code, err := utils.GenerateRandomToken(10)
+	if err != nil {
+		return nil, err
+	}
+
+	inviteCode := &models.BoringSpaceInviteCode{
+		BoringSpaceID: spaceID,
+		Code:          code,
+		CreatorID:     creatorID,
+		Role:          defaultSpaceRole(req.Role),
+		MaxUses:       req.MaxUses,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().Add(time.Hour * 24 * time.Duration(req.ExpiresInDays))
+		inviteCode.ExpiresAt = &expiresAt
+	}
+
+	if err := s.repository.CreateInviteCode(ctx, inviteCode); err != nil {
+		return nil, err
+	}
+	return inviteCode, nil
+}
+
+func (s *BoringSpaceInvitationService) GetInviteCodes(ctx context.Context, spaceID uint) ([]*models.BoringSpaceInviteCode, error) {
+	return s.repository.GetInviteCodes(ctx, spaceID)
+}
+
+func (s *BoringSpaceInvitationService) RevokeInviteCode(ctx context.Context, spaceID uint, codeID uint) error {
+	return s.repository.RevokeInviteCode(ctx, spaceID, codeID)
+}
+
+func (s *BoringSpaceInvitationService) JoinWithCode(ctx context.Context, code string, userID uint) (*models.BoringSpaceMember, error) {
+	inviteCode, err := s.repository.GetInviteCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrInvalidInviteCode
+		}
+		return nil, err
+	}
+	// Members and banned users shouldn't burn a use of the code
+	if err := s.ensureCanJoin(ctx, inviteCode.BoringSpaceID, userID); err != nil {
+		return nil, err
+	}
+
+	inviteCode, member, err := s.repository.RedeemInviteCode(ctx, code, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.touchActivity(ctx, member.BoringSpaceID)
+
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err == nil {
+		s.notify(ctx, inviteCode.CreatorID, fmt.Sprintf("%s joined with your invite link", user.Username))
+	}
+	return member, nil
+}
+
+func (s *BoringSpaceInvitationService) RequestToJoin(ctx context.Context, spaceID uint, userID uint, message string) (*models.BoringSpaceJoinRequest, error) {
+	space, err := s.spaces.GetBoringSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureCanJoin(ctx, spaceID, userID); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repository.GetPendingJoinRequest(ctx, spaceID, userID); err == nil {
+		return nil, models.ErrJoinRequestPending
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	request := &models.BoringSpaceJoinRequest{
+		BoringSpaceID: spaceID,
+		UserID:        userID,
+		Message:       message,
+		Status:        models.JoinRequestPending,
+	}
+	if err := s.repository.CreateJoinRequest(ctx, request); err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err == nil {
+		for _, member := range space.Members {
+			if member.Role == models.BSAdmin {
+				s.notify(ctx, member.UserID, fmt.Sprintf("%s asked to join %s", user.Username, space.Name))
+			}
+		}
+	}
+	return request, nil
+}
+
+func (s *BoringSpaceInvitationService) GetJoinRequests(ctx context.Context, spaceID uint) ([]*models.BoringSpaceJoinRequest, error) {
+	return s.repository.GetJoinRequests(ctx, spaceID)
+}
+
+// ReviewJoinRequest approves, adding the requester as a member, or rejects a pending request
+func (s *BoringSpaceInvitationService) ReviewJoinRequest(ctx context.Context, spaceID uint, requestID uint, reviewerID uint, approve bool) (*models.BoringSpaceJoinRequest, error) {
+	request, err := s.repository.GetJoinRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.BoringSpaceID != spaceID {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	status := models.JoinRequestRejected
+	var member *models.BoringSpaceMember
+	if approve {
+		if err := s.ensureCanJoin(ctx, spaceID, request.UserID); err != nil {
+			return nil, err
+		}
+		status = models.JoinRequestApproved
+		member = &models.BoringSpaceMember{
+			BoringSpaceID: spaceID,
+			UserID:        request.UserID,
+			Role:          models.BSMember,
+		}
+	}
+
+	if err := s.repository.ReviewJoinRequest(ctx, requestID, status, reviewerID, member); err != nil {
+		return nil, err
+	}
+	request.Status = status
+	request.ReviewerID = &reviewerID
+	if member != nil {
+		s.touchActivity(ctx, spaceID)
+	}
+
+	space, err := s.spaces.GetBoringSpaceByID(ctx, spaceID)
+	if err == nil {
+		s.notify(ctx, request.UserID, fmt.Sprintf("Your request to join %s was %s", space.Name, status))
+	}
+	return request, nil
+}
+
+// ensureCanJoin turns away members and banned users
+func (s *BoringSpaceInvitationService) ensureCanJoin(ctx context.Context, spaceID uint, userID uint) error {
+	if err := ensureNotBanned(ctx, s.moderation, spaceID, userID); err != nil {
+		return err
+	}
+
+	_, err := s.spaces.GetMember(ctx, spaceID, userID)
+	if err == nil {
+		return models.ErrAlreadySpaceMember
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
+
+// notify is best effort, the membership change already happened
+func (s *BoringSpaceInvitationService) notify(ctx context.Context, userID uint, content string) {
+	if err := s.notifications.Create(ctx, &models.Notification{UserID: userID, Content: content}); err != nil {
+		log.Errorf("Unable to notify user %d: %v", userID, err)
+	}
+}
+
+func (s *BoringSpaceInvitationService) touchActivity(ctx context.Context, spaceID uint) {
+	if err := s.spaces.TouchActivity(ctx, spaceID); err != nil {
+		log.Errorf("Unable to update activity of BoringSpace %d: %v", spaceID, err)
+	}
+}
+
+func defaultSpaceRole(role models.BoringSpaceRole) models.BoringSpaceRole {
+	if role == "" {
+		return models.BSMember
+	}
+	return role
+}
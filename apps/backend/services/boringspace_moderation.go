@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/montekkundan/bored/backend/models"
+)
+
+// The log entry each sanction writes when it is given and when it is lifted
+var spaceSanctionActions = map[models.SpaceSanctionType][2]models.SpaceModerationAction{
+	models.SpaceBan:     {models.SpaceActionBan, models.SpaceActionUnban},
+	models.SpaceMute:    {models.SpaceActionMute, models.SpaceActionUnmute},
+	models.SpaceTimeout: {models.SpaceActionTimeout, models.SpaceActionRemoveTimeout},
+}
+
+type BoringSpaceModerationService struct {
+	repository models.BoringSpaceModerationRepository
+	spaces     models.BoringSpaceRepository
+}
+
+func NewBoringSpaceModerationService(repository models.BoringSpaceModerationRepository, spaces models.BoringSpaceRepository) models.BoringSpaceModerationService {
+	return &BoringSpaceModerationService{
+		repository: repository,
+		spaces:     spaces,
+	}
+}
+
+// Sanction bans, mutes or times out userID. Bans also work on users who aren't members yet.
+func (s *BoringSpaceModerationService) Sanction(ctx context.Context, spaceID uint, userID uint, moderatorID uint, sanctionType models.SpaceSanctionType, req *models.SanctionRequest) (*models.BoringSpaceSanction, error) {
+	if userID == moderatorID {
+		return nil, models.ErrCannotModerateSelf
+	}
+
+	space, err := s.spaces.GetBoringSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	if space.CreatorID == userID {
+		return nil, models.ErrSpaceOwner
+	}
+
+	if sanctionType != models.SpaceBan {
+		if _, err := s.spaces.GetMember(ctx, spaceID, userID); err != nil {
+			return nil, models.ErrNotSpaceMember
+		}
+	}
+
+	duration := time.Duration(req.DurationMinutes) * time.Minute
+	if sanctionType == models.SpaceTimeout && (duration <= 0 || duration > models.MaxSpaceTimeout) {
+		return nil, models.ErrTimeoutDuration
+	}
+
+	sanction := &models.BoringSpaceSanction{
+		BoringSpaceID: spaceID,
+		UserID:        userID,
+		Type:          sanctionType,
+		Reason:        req.Reason,
+		ModeratorID:   moderatorID,
+	}
+	if duration > 0 {
+		expiresAt := time.Now().Add(duration)
+		sanction.ExpiresAt = &expiresAt
+	}
+
+	entry := &models.BoringSpaceModerationLog{
+		BoringSpaceID: spaceID,
+		ModeratorID:   moderatorID,
+		TargetUserID:  userID,
+		Action:        spaceSanctionActions[sanctionType][0],
+		Reason:        req.Reason,
+		ExpiresAt:     sanction.ExpiresAt,
+	}
+	if err := s.repository.AddSanction(ctx, sanction, entry); err != nil {
+		return nil, err
+	}
+	return sanction, nil
+}
+
+func (s *BoringSpaceModerationService) Lift(ctx context.Context, spaceID uint, userID uint, moderatorID uint, sanctionType models.SpaceSanctionType) error {
+	entry := &models.BoringSpaceModerationLog{
+		BoringSpaceID: spaceID,
+		ModeratorID:   moderatorID,
+		TargetUserID:  userID,
+		Action:        spaceSanctionActions[sanctionType][1],
+	}
+	return s.repository.LiftSanction(ctx, spaceID, userID, sanctionType, entry)
+}
+
+func (s *BoringSpaceModerationService) GetSanctions(ctx context.Context, spaceID uint) ([]*models.BoringSpaceSanction, error) {
+	return s.repository.GetSpaceSanctions(ctx, spaceID)
+}
+
+func (s *BoringSpaceModerationService) GetLog(ctx context.Context, spaceID uint, limit, offset int) ([]*models.BoringSpaceModerationLog, error) {
+	if limit <= 0 || limit > maxDirectoryPageSize {
+		limit = defaultDirectoryPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return s.repository.GetLog(ctx, spaceID, limit, offset)
+}
+
+// ensureNotBanned is the check every way into a space goes through
+func ensureNotBanned(ctx context.Context, moderation models.BoringSpaceModerationRepository, spaceID uint, userID uint) error {
+	sanctions, err := moderation.GetActiveSanctions(ctx, spaceID, userID)
+	if err != nil {
+		return err
+	}
+	for _, sanction := range sanctions {
+		if sanction.Type == models.SpaceBan {
+			return models.ErrSpaceBanned
+		}
+	}
+	return nil
+}
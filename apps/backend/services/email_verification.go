@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	verificationResendCooldown = time.Minute
+	verificationResendPerHour  = 5
+)
+
+// SendVerificationEmail issues a fresh single-use token, revoking earlier ones, and mails the link
+func (s *AuthService) SendVerificationEmail(ctx context.Context, user *models.User) error {
+	if err := s.verificationTokenRepo.DeleteForUser(ctx, user.ID, models.EmailVerificationPurpose); err != nil {
+		return err
+	}
+
+	ttl := time.Minute * time.Duration(s.config.EmailVerificationExpiry)
+	token, claims, err := utils.GenerateSignedToken(string(models.EmailVerificationPurpose), user.ID, ttl, s.config.VerificationTokenSecret)
+	if err != nil {
+		return err
+	}
+
+	err = s.verificationTokenRepo.Create(ctx, &models.VerificationToken{
+		UserID:    user.ID,
+		Purpose:   models.EmailVerificationPurpose,
+		TokenHash: utils.HashToken(claims.Nonce),
+		ExpiresAt: claims.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", s.config.AppBaseURL, url.QueryEscape(token))
+
+	return s.mailer.Send(ctx, &models.Mail{
+		To:      user.Email,
+		Subject: "Verify your Bored email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nConfirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s. If you did not create an account, you can ignore this email.",
+			user.Username, link, ttl,
+		),
+	})
+}
+
+func (s *AuthService) VerifyEmail(ctx context.Context, token string, device *models.DeviceInfo) error {
+	claims, err := utils.ParseSignedToken(token, string(models.EmailVerificationPurpose), s.config.VerificationTokenSecret)
+	if err != nil {
+		return err
+	}
+
+	stored, err := s.verificationTokenRepo.Consume(ctx, models.EmailVerificationPurpose, utils.HashToken(claims.Nonce))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.ErrInvalidSignedToken
+		}
+		return err
+	}
+
+	if stored.UserID != claims.UserID {
+		return utils.ErrInvalidSignedToken
+	}
+
+	if err := s.repository.VerifyEmail(ctx, stored.UserID); err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditEmailVerified, stored.UserID, device, nil)
+	return nil
+}
+
+// ResendVerificationEmail is throttled per address and stays silent about unknown or verified ones.
+// The limits apply before the lookup, so every address gets the same answers.
+func (s *AuthService) ResendVerificationEmail(ctx context.Context, email string) error {
+	key := emailKey(email)
+
+	if err := s.cooldown(ctx, fmt.Sprintf("verify-email:cooldown:%s", key), verificationResendCooldown); err != nil {
+		return err
+	}
+
+	countKey := fmt.Sprintf("verify-email:count:%s", key)
+	count, err := s.redisClient.Incr(ctx, countKey).Result()
+	if err != nil {
+		return err
+	}
+	if count == 1 {
+		s.redisClient.Expire(ctx, countKey, time.Hour)
+	}
+	if count > verificationResendPerHour {
+		return models.ErrTooManyRequests
+	}
+
+	user, err := s.userService.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if user.EmailVerified {
+		return nil
+	}
+
+	return s.SendVerificationEmail(ctx, user)
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/utils"
+)
+
+// Impersonate lets an admin see the app as targetID does. The token can't be refreshed and
+// sensitive account routes refuse it, see middlewares.RejectImpersonation.
+func (s *AuthService) Impersonate(ctx context.Context, adminID uint, targetID uint, reason string, device *models.DeviceInfo) (*models.ImpersonationToken, error) {
+	if adminID == targetID {
+		return nil, models.ErrImpersonationNotAllowed
+	}
+
+	admin, err := s.userService.GetUserByID(ctx, adminID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.userService.GetUserByID(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if target.HasRole(models.Admin) {
+		return nil, models.ErrImpersonationNotAllowed
+	}
+
+	random, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	sessionID := "imp_" + random
+
+	accessToken, err := utils.GenerateImpersonationToken(s.keys, target.ID, target.Roles, target.TokenVersion, sessionID, admin.ID, admin.TokenVersion, s.config.ImpersonationTokenExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, &models.AuditLog{
+		Type:      models.AuditImpersonationStarted,
+		ActorID:   &admin.ID,
+		TargetID:  &target.ID,
+		IPAddress: device.IPAddress,
+		UserAgent: device.UserAgent,
+		Metadata:  map[string]interface{}{"session_id": sessionID, "reason": reason},
+	})
+
+	return &models.ImpersonationToken{
+		AccessToken:    accessToken,
+		ExpiresAt:      time.Now().Add(time.Minute * time.Duration(s.config.ImpersonationTokenExpiry)),
+		UserID:         target.ID,
+		ImpersonatorID: admin.ID,
+	}, nil
+}
+
+// EndImpersonation revokes the impersonation session before it would expire on its own
+func (s *AuthService) EndImpersonation(ctx context.Context, sessionID string, adminID uint, userID uint, device *models.DeviceInfo) error {
+	expiry := time.Minute * time.Duration(s.config.ImpersonationTokenExpiry)
+	if err := s.redisClient.Set(ctx, utils.SessionRevokedKey(sessionID), 1, expiry).Err(); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, &models.AuditLog{
+		Type:      models.AuditImpersonationEnded,
+		ActorID:   &adminID,
+		TargetID:  &userID,
+		IPAddress: device.IPAddress,
+		UserAgent: device.UserAgent,
+		Metadata:  map[string]interface{}{"session_id": sessionID},
+	})
+	return nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/montekkundan/bored/backend/models"
+)
+
+func accountFailuresKey(scope models.LockoutScope, userID uint) string {
+	return fmt.Sprintf("auth-fail:%s:user:%d", scope, userID)
+}
+
+func accountLockKey(scope models.LockoutScope, userID uint) string {
+	return fmt.Sprintf("auth-lock:%s:user:%d", scope, userID)
+}
+
+func ipFailuresKey(scope models.LockoutScope, ip string) string {
+	return fmt.Sprintf("auth-fail:%s:ip:%s", scope, ip)
+}
+
+func ipLockKey(scope models.LockoutScope, ip string) string {
+	return fmt.Sprintf("auth-lock:%s:ip:%s", scope, ip)
+}
+
+// checkLockout fails with a *models.LockoutError while the account or the IP is locked for scope.
+// A zero userID or empty ip skips that half of the check.
+func (s *AuthService) checkLockout(ctx context.Context, scope models.LockoutScope, userID uint, ip string) error {
+	keys := []string{}
+	if userID != 0 {
+		keys = append(keys, accountLockKey(scope, userID))
+	}
+	if ip != "" {
+		keys = append(keys, ipLockKey(scope, ip))
+	}
+
+	var retryAfter time.Duration
+	for _, key := range keys {
+		ttl, err := s.redisClient.PTTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if ttl > retryAfter {
+			retryAfter = ttl
+		}
+	}
+
+	if retryAfter > 0 {
+		return &models.LockoutError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// recordFailure counts a failed attempt and, once a threshold is crossed, locks the account or IP
+// for a period that doubles with every further failure
+func (s *AuthService) recordFailure(ctx context.Context, scope models.LockoutScope, userID uint, ip string) error {
+	if userID != 0 {
+		failures, err := s.incrFailures(ctx, accountFailuresKey(scope, userID))
+		if err != nil {
+			return err
+		}
+		if failures >= int64(s.config.LockoutThreshold) {
+			if err := s.lock(ctx, accountLockKey(scope, userID), failures-int64(s.config.LockoutThreshold)); err != nil {
+				return err
+			}
+			// Only the lock that starts a run of failures is worth telling the user about
+			if failures == int64(s.config.LockoutThreshold) {
+				s.notifyLockout(ctx, userID, scope)
+				s.audit.Record(ctx, &models.AuditLog{
+					Type:      models.AuditAccountLocked,
+					TargetID:  &userID,
+					IPAddress: ip,
+					Metadata:  map[string]interface{}{"scope": scope, "failures": failures},
+				})
+			}
+		}
+	}
+
+	if ip != "" {
+		failures, err := s.incrFailures(ctx, ipFailuresKey(scope, ip))
+		if err != nil {
+			return err
+		}
+		if failures >= int64(s.config.IPLockoutThreshold) {
+			if err := s.lock(ctx, ipLockKey(scope, ip), failures-int64(s.config.IPLockoutThreshold)); err != nil {
+				return err
+			}
+			if failures == int64(s.config.IPLockoutThreshold) {
+				log.Warnf("Locked out %s from %s after %d failed attempts", ip, scope, failures)
+			}
+		}
+	}
+
+	return nil
+}
+
+// clearFailures resets the account counter after a successful attempt. IP counters are left
+// to expire so one good login can't launder a credential-stuffing run.
+func (s *AuthService) clearFailures(ctx context.Context, scope models.LockoutScope, userID uint) error {
+	return s.redisClient.Del(ctx, accountFailuresKey(scope, userID), accountLockKey(scope, userID)).Err()
+}
+
+// incrFailures bumps a counter that is forgotten once the maximum lockout has passed without failures
+func (s *AuthService) incrFailures(ctx context.Context, key string) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, s.maxLockout())
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (s *AuthService) lock(ctx context.Context, key string, excess int64) error {
+	d := time.Minute * time.Duration(s.config.LockoutBaseDuration)
+	for i := int64(0); i < excess && d < s.maxLockout(); i++ {
+		d *= 2
+	}
+	if d > s.maxLockout() {
+		d = s.maxLockout()
+	}
+	return s.redisClient.Set(ctx, key, 1, d).Err()
+}
+
+func (s *AuthService) maxLockout() time.Duration {
+	return time.Minute * time.Duration(s.config.LockoutMaxDuration)
+}
+
+// notifyLockout is best effort, a failed email must not turn into a failed lockout
+func (s *AuthService) notifyLockout(ctx context.Context, userID uint, scope models.LockoutScope) {
+	user, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Errorf("Unable to load user %d for lockout notice: %v", userID, err)
+		return
+	}
+
+	what := map[models.LockoutScope]string{
+		models.LoginLockout:     "sign in to",
+		models.TwoFactorLockout: "enter a two-factor code for",
+		models.PhoneLockout:     "verify a phone number on",
+	}[scope]
+
+	err = s.mailer.Send(ctx, &models.Mail{
+		To:      user.Email,
+		Subject: "Your Bored account has been temporarily locked",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThere were %d failed attempts to %s your account, so we've paused further attempts for a while.\n\nIf this wasn't you, we recommend changing your password. If you're still locked out, contact an administrator.",
+			user.Username, s.config.LockoutThreshold, what,
+		),
+	})
+	if err != nil {
+		log.Errorf("Unable to send lockout notice to user %d: %v", userID, err)
+	}
+}
+
+// UnlockAccount lifts every lockout on the account and resets its failure counters
+func (s *AuthService) UnlockAccount(ctx context.Context, userID uint) error {
+	if _, err := s.userService.GetUserByID(ctx, userID); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(models.LockoutScopes)*2)
+	for _, scope := range models.LockoutScopes {
+		keys = append(keys, accountFailuresKey(scope, userID), accountLockKey(scope, userID))
+	}
+	return s.redisClient.Del(ctx, keys...).Err()
+}
+
+// UnlockIP lifts every lockout on an IP address, e.g. a shared office NAT
+func (s *AuthService) UnlockIP(ctx context.Context, ip string) error {
+	keys := make([]string, 0, len(models.LockoutScopes)*2)
+	for _, scope := range models.LockoutScopes {
+		keys = append(keys, ipFailuresKey(scope, ip), ipLockKey(scope, ip))
+	}
+	return s.redisClient.Del(ctx, keys...).Err()
+}
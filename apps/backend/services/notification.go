@@ -18,8 +18,8 @@ func (s *NotificationService) MarkAsRead(ctx context.Context, notificationID uin
 	return s.repository.MarkAsRead(ctx, notificationID)
 }
 
-func (s *NotificationService) GetNotifications(ctx context.Context, userID uint) ([]*models.Notification, error) {
-	return s.repository.GetNotifications(ctx, userID)
+func (s *NotificationService) GetNotifications(ctx context.Context, userID uint, page *models.PageRequest) ([]*models.Notification, string, error) {
+	return s.repository.GetNotifications(ctx, userID, page)
 }
 
 func NewNotificationService(repository models.NotificationRepository) *NotificationService {
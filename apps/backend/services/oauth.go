@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/oidc"
+	"github.com/montekkundan/bored/backend/utils"
+	"gorm.io/gorm"
+)
+
+var usernameUnsafeChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// oauthState is kept in Redis between the authorization redirect and the callback
+type oauthState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	LinkUserID   uint   `json:"link_user_id,omitempty"`
+}
+
+type OAuthService struct {
+	providers      map[string]*oidc.Provider
+	repository     models.OAuthProviderRepository
+	authRepository models.AuthRepository
+	authService    models.AuthService
+	userService    models.UserService
+	redisClient    *redis.Client
+	hasher         models.PasswordHasher
+}
+
+func (s *OAuthService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AuthorizationURL starts a login, or links the provider to linkUserID when it is non-zero
+func (s *OAuthService) AuthorizationURL(ctx context.Context, provider string, linkUserID uint) (string, string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", "", models.ErrUnknownOAuthProvider
+	}
+
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.RandomString(48)
+	if err != nil {
+		return "", "", err
+	}
+
+	payload, err := json.Marshal(&oauthState{
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := s.redisClient.Set(ctx, oauthStateKey(state), payload, models.OAuthStateTTL).Err(); err != nil {
+		return "", "", err
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+// HandleCallback finishes a flow started by AuthorizationURL. stateBinding must be the hash of the state
+// kept by the browser that started it, so nobody can make a victim complete a flow someone else started.
+func (s *OAuthService) HandleCallback(ctx context.Context, provider string, code string, state string, stateBinding string, device *models.DeviceInfo) (map[string]string, *models.User, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, nil, models.ErrUnknownOAuthProvider
+	}
+
+	if stateBinding == "" || subtle.ConstantTimeCompare([]byte(utils.HashToken(state)), []byte(stateBinding)) != 1 {
+		return nil, nil, models.ErrInvalidOAuthState
+	}
+
+	// State is single use
+	raw, err := s.redisClient.GetDel(ctx, oauthStateKey(state)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil, models.ErrInvalidOAuthState
+		}
+		return nil, nil, err
+	}
+
+	stored := &oauthState{}
+	if err := json.Unmarshal([]byte(raw), stored); err != nil || stored.Provider != provider {
+		return nil, nil, models.ErrInvalidOAuthState
+	}
+
+	token, err := p.Exchange(ctx, code, stored.CodeVerifier)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	claims, err := p.VerifyIDToken(ctx, token.IDToken, stored.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.resolveUser(ctx, provider, claims, token.AccessToken, stored.LinkUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if user.Deactivated {
+		return nil, nil, errors.New("account is deactivated")
+	}
+
+	// Goes through the lockout and 2FA like a password login would
+	tokens, err := s.authService.LoginExternal(ctx, user, device)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tokens, user, nil
+}
+
+func (s *OAuthService) GetLinkedProviders(ctx context.Context, userID uint) ([]*models.OAuthProvider, error) {
+	return s.repository.GetProvidersByUser(ctx, userID)
+}
+
+func (s *OAuthService) UnlinkProvider(ctx context.Context, userID uint, id uint) error {
+	return s.repository.DeleteProvider(ctx, userID, id)
+}
+
+// resolveUser finds the account for an external identity: an existing link first, then an explicit
+// link request, then a verified account with the same verified email, and finally a new account
+func (s *OAuthService) resolveUser(ctx context.Context, provider string, claims *oidc.IDTokenClaims, accessToken string, linkUserID uint) (*models.User, error) {
+	link, err := s.repository.GetByProviderID(ctx, provider, claims.Subject)
+	if err == nil {
+		if linkUserID != 0 && link.UserID != linkUserID {
+			return nil, errors.New("this account is already linked to another user")
+		}
+		if err := s.repository.UpdateToken(ctx, link.ID, accessToken); err != nil {
+			return nil, err
+		}
+		return s.userService.GetUserByID(ctx, link.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var user *models.User
+	switch {
+	case linkUserID != 0:
+		user, err = s.userService.GetUserByID(ctx, linkUserID)
+	case claims.Email != "" && claims.EmailVerified:
+		user, err = s.userService.GetUserByEmail(ctx, claims.Email)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			user, err = s.createUser(ctx, claims)
+		} else if err == nil && !user.EmailVerified {
+			// Whoever registered the address never proved they own it, it may be a squatter waiting for
+			// the real owner to sign in. Only they can link it, by logging in and using /:provider/link.
+			return nil, models.ErrUnverifiedAccountExists
+		}
+	default:
+		user, err = s.createUser(ctx, claims)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repository.AddProvider(ctx, &models.OAuthProvider{
+		UserID:     user.ID,
+		Provider:   provider,
+		ProviderID: claims.Subject,
+		Email:      claims.Email,
+		Token:      accessToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *OAuthService) createUser(ctx context.Context, claims *oidc.IDTokenClaims) (*models.User, error) {
+	if claims.Email == "" {
+		return nil, errors.New("the provider did not share an email address")
+	}
+
+	username, err := s.availableUsername(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// The account has no usable password until the user sets one through forgot-password
+	password, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	passwordHash, err := s.hasher.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.authRepository.RegisterUser(ctx, &models.AuthCredentials{
+		Email:    claims.Email,
+		Username: username,
+		Password: passwordHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.EmailVerified {
+		if err := s.authRepository.VerifyEmail(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		user.EmailVerified = true
+	}
+
+	return user, nil
+}
+
+func (s *OAuthService) availableUsername(ctx context.Context, claims *oidc.IDTokenClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = usernameUnsafeChars.ReplaceAllString(strings.ToLower(base), "")
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		if _, err := s.userService.GetUserByUsername(ctx, candidate); errors.Is(err, gorm.ErrRecordNotFound) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+
+		suffix, err := utils.GenerateNumericCode(4)
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s_%s", base, suffix)
+	}
+
+	return "", errors.New("could not find an available username")
+}
+
+func oauthStateKey(state string) string {
+	return fmt.Sprintf("oauth:state:%s", state)
+}
+
+func NewOAuthService(
+	providers map[string]*oidc.Provider,
+	repository models.OAuthProviderRepository,
+	authRepository models.AuthRepository,
+	authService models.AuthService,
+	userService models.UserService,
+	redisClient *redis.Client,
+	hasher models.PasswordHasher,
+) models.OAuthService {
+	return &OAuthService{
+		providers:      providers,
+		repository:     repository,
+		authRepository: authRepository,
+		authService:    authService,
+		userService:    userService,
+		redisClient:    redisClient,
+		hasher:         hasher,
+	}
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/utils"
+	"gorm.io/gorm"
+)
+
+const passwordResetCooldown = time.Minute
+
+// ForgotPassword mails a single-use reset link and stays silent about unknown addresses.
+// The cooldown is keyed on the address and applied before the lookup, so it can't tell them apart either.
+func (s *AuthService) ForgotPassword(ctx context.Context, email string, device *models.DeviceInfo) error {
+	if err := s.cooldown(ctx, fmt.Sprintf("password-reset:cooldown:%s", emailKey(email)), passwordResetCooldown); err != nil {
+		return err
+	}
+
+	user, err := s.userService.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// Only the most recent link is usable
+	if err := s.verificationTokenRepo.DeleteForUser(ctx, user.ID, models.PasswordResetPurpose); err != nil {
+		return err
+	}
+
+	ttl := time.Minute * time.Duration(s.config.PasswordResetExpiry)
+	token, claims, err := utils.GenerateSignedToken(string(models.PasswordResetPurpose), user.ID, ttl, s.config.VerificationTokenSecret)
+	if err != nil {
+		return err
+	}
+
+	err = s.verificationTokenRepo.Create(ctx, &models.VerificationToken{
+		UserID:    user.ID,
+		Purpose:   models.PasswordResetPurpose,
+		TokenHash: utils.HashToken(claims.Nonce),
+		ExpiresAt: claims.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditPasswordResetRequested, user.ID, device, nil)
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", s.config.AppBaseURL, url.QueryEscape(token))
+
+	return s.mailer.Send(ctx, &models.Mail{
+		To:      user.Email,
+		Subject: "Reset your Bored password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password for your account. Choose a new one here:\n\n%s\n\nThe link expires in %s. If this wasn't you, you can ignore this email.",
+			user.Username, link, ttl,
+		),
+	})
+}
+
+func (s *AuthService) ResetPassword(ctx context.Context, token string, newPassword string, device *models.DeviceInfo) error {
+	claims, err := utils.ParseSignedToken(token, string(models.PasswordResetPurpose), s.config.VerificationTokenSecret)
+	if err != nil {
+		return err
+	}
+
+	stored, err := s.verificationTokenRepo.Consume(ctx, models.PasswordResetPurpose, utils.HashToken(claims.Nonce))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.ErrInvalidSignedToken
+		}
+		return err
+	}
+
+	if stored.UserID != claims.UserID {
+		return utils.ErrInvalidSignedToken
+	}
+
+	user, err := s.userService.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditPasswordReset, user.ID, device, nil)
+	return nil
+}
+
+func (s *AuthService) ChangePassword(ctx context.Context, userID uint, currentPassword string, newPassword string) error {
+	user, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	matches, err := s.hasher.Verify(currentPassword, user.PasswordHash)
+	if err != nil {
+		return err
+	}
+	if !matches {
+		return errors.New("current password is incorrect")
+	}
+
+	return s.setPassword(ctx, user, newPassword)
+}
+
+// setPassword stores the new hash, ends every session and tells the user about the change
+func (s *AuthService) setPassword(ctx context.Context, user *models.User, newPassword string) error {
+	if err := s.passwordPolicy.Validate(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repository.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		return err
+	}
+
+	if err := s.LogoutAll(ctx, user.ID); err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(ctx, &models.Mail{
+		To:      user.Email,
+		Subject: "Your Bored password was changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe password for your account was just changed and all your sessions were signed out. If this wasn't you, reset your password immediately.",
+			user.Username,
+		),
+	})
+	if err != nil {
+		log.Errorf("Unable to send password change notice to user %d: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// rehashPassword moves a legacy hash to the current algorithm. Failing to is not worth failing a login over.
+func (s *AuthService) rehashPassword(ctx context.Context, user *models.User, password string) {
+	hashedPassword, err := s.hasher.Hash(password)
+	if err == nil {
+		err = s.repository.UpdatePassword(ctx, user.ID, hashedPassword)
+	}
+	if err != nil {
+		log.Errorf("Unable to upgrade password hash for user %d: %v", user.ID, err)
+		return
+	}
+	user.PasswordHash = hashedPassword
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/utils"
+)
+
+const (
+	phoneCodeDigits      = 6
+	phoneCodeTTL         = 10 * time.Minute
+	phoneCodeCooldown    = time.Minute
+	phoneCodeMaxAttempts = 5
+)
+
+func phoneCodeKey(userID uint) string {
+	return fmt.Sprintf("phone-otp:%d", userID)
+}
+
+func hashPhoneCode(userID uint, phoneNumber, code string) string {
+	return utils.HashToken(fmt.Sprintf("%d:%s:%s", userID, phoneNumber, code))
+}
+
+// RequestPhoneVerification sends a one-time code to the user's phone, or to phoneNumber when they are
+// changing it. A new number stays pending in Redis and only replaces the old one once its code is confirmed.
+func (s *AuthService) RequestPhoneVerification(ctx context.Context, userID uint, phoneNumber string) error {
+	if phoneNumber != "" && !models.IsValidPhoneNumber(phoneNumber) {
+		return errors.New("please provide a phone number in E.164 format, e.g. +14155550123")
+	}
+
+	if err := s.cooldown(ctx, fmt.Sprintf("phone-otp:cooldown:%d", userID), phoneCodeCooldown); err != nil {
+		return err
+	}
+
+	user, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if phoneNumber == "" {
+		phoneNumber = user.PhoneNumber
+	}
+	if phoneNumber == "" {
+		return errors.New("no phone number on file")
+	}
+	if phoneNumber == user.PhoneNumber && user.PhoneVerified {
+		return errors.New("phone number already verified")
+	}
+
+	code, err := utils.GenerateNumericCode(phoneCodeDigits)
+	if err != nil {
+		return err
+	}
+
+	// Only the hash is stored, bound to the number the code was sent to
+	key := phoneCodeKey(userID)
+	_, err = s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, "hash", hashPhoneCode(userID, phoneNumber, code), "phone", phoneNumber, "attempts", 0)
+		pipe.Expire(ctx, key, phoneCodeTTL)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.smsSender.Send(ctx, phoneNumber, fmt.Sprintf("Your Bored verification code is %s. It expires in %s.", code, phoneCodeTTL))
+}
+
+// VerifyPhoneNumber confirms the pending code and saves the number it was sent to as verified
+func (s *AuthService) VerifyPhoneNumber(ctx context.Context, userID uint, code string) error {
+	if err := s.checkLockout(ctx, models.PhoneLockout, userID, ""); err != nil {
+		return err
+	}
+
+	key := phoneCodeKey(userID)
+	pending, err := s.redisClient.HMGet(ctx, key, "hash", "phone").Result()
+	if err != nil {
+		return err
+	}
+	stored, _ := pending[0].(string)
+	phoneNumber, _ := pending[1].(string)
+	if stored == "" || phoneNumber == "" {
+		return models.ErrInvalidPhoneCode
+	}
+
+	attempts, err := s.redisClient.HIncrBy(ctx, key, "attempts", 1).Result()
+	if err != nil {
+		return err
+	}
+	if attempts > phoneCodeMaxAttempts {
+		s.redisClient.Del(ctx, key)
+		return models.ErrInvalidPhoneCode
+	}
+
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(hashPhoneCode(userID, phoneNumber, code))) != 1 {
+		if err := s.recordFailure(ctx, models.PhoneLockout, userID, ""); err != nil {
+			return err
+		}
+		return models.ErrInvalidPhoneCode
+	}
+
+	if err := s.clearFailures(ctx, models.PhoneLockout, userID); err != nil {
+		return err
+	}
+
+	if err := s.repository.MarkPhoneVerified(ctx, userID, phoneNumber); err != nil {
+		return err
+	}
+
+	return s.redisClient.Del(ctx, key).Err()
+}
@@ -3,27 +3,42 @@ package services
 import (
 	"context"
 
+	"github.com/gofiber/fiber/v2/log"
 	"github.com/montekkundan/bored/backend/models"
 )
 
 type PublicMessageService struct {
-	repo models.PublicMessageRepository
+	repo   models.PublicMessageRepository
+	spaces models.BoringSpaceRepository
 }
 
-func NewPublicMessageService(repo models.PublicMessageRepository) models.PublicMessageService {
-	return &PublicMessageService{repo: repo}
+func NewPublicMessageService(repo models.PublicMessageRepository, spaces models.BoringSpaceRepository) models.PublicMessageService {
+	return &PublicMessageService{repo: repo, spaces: spaces}
 }
 
 func (s *PublicMessageService) CreatePublicMessage(ctx context.Context, message *models.PublicMessage) error {
-	return s.repo.CreatePublicMessage(ctx, message)
+	if err := s.repo.CreatePublicMessage(ctx, message); err != nil {
+		return err
+	}
+
+	if message.BoringSpaceID != nil {
+		if err := s.spaces.TouchActivity(ctx, *message.BoringSpaceID); err != nil {
+			log.Errorf("Unable to update activity of BoringSpace %d: %v", *message.BoringSpaceID, err)
+		}
+	}
+	return nil
 }
 
 func (s *PublicMessageService) GetPublicMessageByID(ctx context.Context, messageID uint) (*models.PublicMessage, error) {
 	return s.repo.GetPublicMessageByID(ctx, messageID)
 }
 
-func (s *PublicMessageService) GetPublicMessages(ctx context.Context, limit, offset int) ([]*models.PublicMessage, error) {
-	return s.repo.GetPublicMessages(ctx, limit, offset)
+func (s *PublicMessageService) GetPublicMessages(ctx context.Context, page *models.PageRequest) ([]*models.PublicMessage, string, error) {
+	return s.repo.GetPublicMessages(ctx, page)
+}
+
+func (s *PublicMessageService) GetSpaceMessages(ctx context.Context, spaceID uint, page *models.PageRequest) ([]*models.PublicMessage, string, error) {
+	return s.repo.GetSpaceMessages(ctx, spaceID, page)
 }
 
 func (s *PublicMessageService) DeletePublicMessage(ctx context.Context, messageID uint) error {
@@ -42,6 +57,6 @@ func (s *PublicMessageService) CreateComment(ctx context.Context, comment *model
 	return s.repo.CreateComment(ctx, comment)
 }
 
-func (s *PublicMessageService) GetCommentsByMessageID(ctx context.Context, messageID uint) ([]*models.Comment, error) {
-	return s.repo.GetCommentsByMessageID(ctx, messageID)
+func (s *PublicMessageService) GetCommentsByMessageID(ctx context.Context, messageID uint, page *models.PageRequest) ([]*models.Comment, string, error) {
+	return s.repo.GetCommentsByMessageID(ctx, messageID, page)
 }
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/utils"
+	"gorm.io/gorm"
+)
+
+func (s *AuthService) ListSessions(ctx context.Context, userID uint, currentSessionID string) ([]*models.Session, error) {
+	tokens, err := s.refreshTokenRepo.ListActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*models.Session, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, &models.Session{
+			ID:         token.FamilyID,
+			UserAgent:  token.UserAgent,
+			IPAddress:  token.IPAddress,
+			CreatedAt:  token.SessionStartedAt,
+			LastUsedAt: token.LastUsedAt,
+			ExpiresAt:  token.ExpiresAt,
+			Current:    token.FamilyID == currentSessionID,
+		})
+	}
+
+	return sessions, nil
+}
+
+func (s *AuthService) RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	tokens, err := s.refreshTokenRepo.ListActive(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		if token.FamilyID == sessionID {
+			return s.revokeSessions(ctx, userID, []string{sessionID})
+		}
+	}
+
+	return gorm.ErrRecordNotFound
+}
+
+func (s *AuthService) RevokeOtherSessions(ctx context.Context, userID uint, currentSessionID string) error {
+	tokens, err := s.refreshTokenRepo.ListActive(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	sessionIDs := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if token.FamilyID != currentSessionID {
+			sessionIDs = append(sessionIDs, token.FamilyID)
+		}
+	}
+
+	return s.revokeSessions(ctx, userID, sessionIDs)
+}
+
+// revokeSessions kills the refresh token families and flags their sids so AuthProtected
+// rejects access tokens already handed out for them
+func (s *AuthService) revokeSessions(ctx context.Context, userID uint, sessionIDs []string) error {
+	if err := s.refreshTokenRepo.RevokeUserFamilies(ctx, userID, sessionIDs); err != nil {
+		return err
+	}
+
+	ttl := time.Minute * time.Duration(s.config.AccessTokenExpiry)
+	for _, sessionID := range sessionIDs {
+		if err := s.redisClient.Set(ctx, utils.SessionRevokedKey(sessionID), "revoked", ttl).Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
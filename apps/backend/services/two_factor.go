@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/montekkundan/bored/backend/models"
+	"github.com/montekkundan/bored/backend/utils"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	recoveryCodeCount     = 10
+	totpSkew              = 1
+	twoFactorChallengeTTL = 5 * time.Minute
+)
+
+func (s *AuthService) SetupTwoFactor(ctx context.Context, userID uint) (*models.TwoFactorSetup, error) {
+	user, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	// The secret stays pending until the user proves possession with ConfirmTwoFactor
+	user.TwoFactorSecret = secret
+	user.TwoFactorStep = 0
+	if err := s.userService.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	uri := utils.TOTPProvisioningURI(s.config.TwoFactorIssuer, user.Email, secret)
+	qr, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: uri,
+		QRCode:          qr,
+	}, nil
+}
+
+func (s *AuthService) ConfirmTwoFactor(ctx context.Context, userID uint, code string) ([]string, error) {
+	user, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if user.TwoFactorSecret == "" {
+		return nil, errors.New("two-factor setup has not been started")
+	}
+
+	if err := s.checkLockout(ctx, models.TwoFactorLockout, user.ID, ""); err != nil {
+		return nil, err
+	}
+
+	step, ok := utils.ValidateTOTPCode(user.TwoFactorSecret, code, time.Now(), totpSkew)
+	if !ok {
+		if err := s.recordFailure(ctx, models.TwoFactorLockout, user.ID, ""); err != nil {
+			return nil, err
+		}
+		return nil, models.ErrInvalidTwoFACode
+	}
+
+	if err := s.clearFailures(ctx, models.TwoFactorLockout, user.ID); err != nil {
+		return nil, err
+	}
+
+	user.TwoFactorEnabled = true
+	user.TwoFactorStep = step
+	if err := s.userService.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return s.issueRecoveryCodes(ctx, user.ID)
+}
+
+func (s *AuthService) DisableTwoFactor(ctx context.Context, userID uint, code string) error {
+	user, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.TwoFactorEnabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+
+	ok, err := s.IsValidTwoFACode(ctx, user, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return models.ErrInvalidTwoFACode
+	}
+
+	user.TwoFactorEnabled = false
+	user.TwoFactorSecret = ""
+	user.TwoFactorStep = 0
+	if err := s.userService.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	return s.recoveryCodeRepo.DeleteCodes(ctx, user.ID)
+}
+
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error) {
+	user, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.TwoFactorEnabled {
+		return nil, errors.New("two-factor authentication is not enabled")
+	}
+
+	ok, err := s.IsValidTwoFACode(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, models.ErrInvalidTwoFACode
+	}
+
+	return s.issueRecoveryCodes(ctx, user.ID)
+}
+
+// CompleteTwoFactorChallenge finishes a login LoginExternal held back for a 2FA code. A wrong code
+// leaves the challenge open until it expires, the 2FA lockout bounds how often it can be guessed.
+func (s *AuthService) CompleteTwoFactorChallenge(ctx context.Context, challenge string, code string, device *models.DeviceInfo) (map[string]string, *models.User, error) {
+	key := twoFactorChallengeKey(challenge)
+
+	userID, err := s.redisClient.Get(ctx, key).Uint64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil, models.ErrInvalidChallenge
+		}
+		return nil, nil, err
+	}
+
+	user, err := s.userService.GetUserByID(ctx, uint(userID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ok, err := s.verifyTwoFACode(ctx, user, code, device.IPAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		s.recordAudit(ctx, models.AuditLoginFailed, user.ID, device, map[string]interface{}{"reason": "wrong two-factor code"})
+		return nil, nil, models.ErrInvalidTwoFACode
+	}
+
+	// Only one request gets to spend the challenge
+	deleted, err := s.redisClient.Del(ctx, key).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	if deleted == 0 {
+		return nil, nil, models.ErrInvalidChallenge
+	}
+
+	if err := s.clearFailures(ctx, models.LoginLockout, user.ID); err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.IssueTokens(ctx, user, device)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.recordAudit(ctx, models.AuditLoginSucceeded, user.ID, device, nil)
+	return tokens, user, nil
+}
+
+// startTwoFactorChallenge remembers which user a challenge belongs to until it is completed or expires
+func (s *AuthService) startTwoFactorChallenge(ctx context.Context, userID uint) (string, error) {
+	challenge, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.redisClient.Set(ctx, twoFactorChallengeKey(challenge), userID, twoFactorChallengeTTL).Err(); err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+func twoFactorChallengeKey(challenge string) string {
+	return fmt.Sprintf("2fa-challenge:%s", utils.HashToken(challenge))
+}
+
+// IsValidTwoFACode accepts either a current TOTP code or an unused recovery code
+func (s *AuthService) IsValidTwoFACode(ctx context.Context, user *models.User, twoFACode string) (bool, error) {
+	return s.verifyTwoFACode(ctx, user, twoFACode, "")
+}
+
+// verifyTwoFACode is IsValidTwoFACode behind the 2FA lockout, also tracking ip when known
+func (s *AuthService) verifyTwoFACode(ctx context.Context, user *models.User, twoFACode string, ip string) (bool, error) {
+	if err := s.checkLockout(ctx, models.TwoFactorLockout, user.ID, ip); err != nil {
+		return false, err
+	}
+
+	ok, err := s.matchTwoFACode(ctx, user, twoFACode)
+	if err != nil {
+		return false, err
+	}
+
+	if !ok {
+		return false, s.recordFailure(ctx, models.TwoFactorLockout, user.ID, ip)
+	}
+	return true, s.clearFailures(ctx, models.TwoFactorLockout, user.ID)
+}
+
+func (s *AuthService) matchTwoFACode(ctx context.Context, user *models.User, twoFACode string) (bool, error) {
+	if user.TwoFactorSecret == "" {
+		return false, nil
+	}
+
+	if step, ok := utils.ValidateTOTPCode(user.TwoFactorSecret, twoFACode, time.Now(), totpSkew); ok {
+		// A code may only be used once within its window
+		if step <= user.TwoFactorStep {
+			return false, nil
+		}
+		user.TwoFactorStep = step
+		if err := s.userService.UpdateUser(ctx, user); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return s.recoveryCodeRepo.ConsumeCode(ctx, user.ID, utils.HashToken(normalizeRecoveryCode(twoFACode)))
+}
+
+// issueRecoveryCodes replaces the user's recovery codes and returns the plaintext set once
+func (s *AuthService) issueRecoveryCodes(ctx context.Context, userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := utils.GenerateRandomToken(5)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, fmt.Sprintf("%s-%s", raw[:4], raw[4:]))
+		hashes = append(hashes, utils.HashToken(raw))
+	}
+
+	if err := s.recoveryCodeRepo.ReplaceCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
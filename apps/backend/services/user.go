@@ -38,12 +38,16 @@ func (s *UserService) DeactivateUser(ctx context.Context, userID uint) error {
 	return s.repository.DeactivateUser(ctx, userID)
 }
 
+func (s *UserService) IncrementTokenVersion(ctx context.Context, userID uint) error {
+	return s.repository.IncrementTokenVersion(ctx, userID)
+}
+
 func (s *UserService) GetUserBoringSpaces(ctx context.Context, userID uint) ([]*models.BoringSpaceMember, error) {
 	return s.repository.GetUserBoringSpaces(ctx, userID)
 }
 
-func (s *UserService) GetAllPublicMessages(ctx context.Context, limit, offset int) ([]*models.PublicMessage, error) {
-	return s.repository.GetAllPublicMessages(ctx, limit, offset)
+func (s *UserService) GetAllPublicMessages(ctx context.Context, page *models.PageRequest) ([]*models.PublicMessage, string, error) {
+	return s.repository.GetAllPublicMessages(ctx, page)
 }
 
 func NewUserService(repository models.UserRepository) models.UserService {
@@ -0,0 +1,171 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is one signing or verification key, identified by its kid
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer // nil for retired keys kept only to verify outstanding tokens
+	Public  crypto.PublicKey
+}
+
+// KeyManager signs tokens with the active key and verifies them with any known key,
+// which lets keys be rotated without invalidating tokens that are still in flight
+type KeyManager struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+// LoadKeyManager reads every PEM file in dir. "<kid>.pem" holds a private key (RSA or Ed25519, PKCS#8 or PKCS#1),
+// "<kid>.pub.pem" a public key kept for verification only. The active key is activeKID or, when empty,
+// the private key whose kid sorts last. Without a directory an ephemeral Ed25519 key is generated.
+func LoadKeyManager(dir string, activeKID string) (*KeyManager, error) {
+	manager := &KeyManager{keys: map[string]*Key{}}
+
+	if dir == "" {
+		log.Warn("JWT_KEYS_DIR is not set, signing with an ephemeral key that will not survive a restart")
+		key, err := generateEphemeralKey()
+		if err != nil {
+			return nil, err
+		}
+		manager.keys[key.ID] = key
+		manager.active = key
+		return manager, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var lastPrivate *Key
+	for _, path := range paths {
+		key, err := loadKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		manager.keys[key.ID] = key
+		if key.Private != nil {
+			lastPrivate = key
+		}
+	}
+
+	switch {
+	case activeKID != "":
+		key, ok := manager.keys[activeKID]
+		if !ok || key.Private == nil {
+			return nil, fmt.Errorf("active key %q has no private key in %s", activeKID, dir)
+		}
+		manager.active = key
+	case lastPrivate != nil:
+		manager.active = lastPrivate
+	default:
+		return nil, fmt.Errorf("no private signing key found in %s", dir)
+	}
+
+	return manager, nil
+}
+
+// Sign issues a token with the active key and its kid in the header
+func (m *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(m.active.Method, claims)
+	token.Header["kid"] = m.active.ID
+	return token.SignedString(m.active.Private)
+}
+
+// Parse verifies a token against the key named by its kid
+func (m *KeyManager) Parse(tokenStr string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := m.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.Public, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
+}
+
+func loadKey(path string) (*Key, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	name := filepath.Base(path)
+	if strings.HasSuffix(name, ".pub.pem") {
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return newKey(strings.TrimSuffix(name, ".pub.pem"), nil, public)
+	}
+
+	var private interface{}
+	if block.Type == "RSA PRIVATE KEY" {
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key")
+	}
+	return newKey(strings.TrimSuffix(name, ".pem"), signer, signer.Public())
+}
+
+func newKey(id string, private crypto.Signer, public crypto.PublicKey) (*Key, error) {
+	switch public.(type) {
+	case *rsa.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, Private: private, Public: public}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, Private: private, Public: public}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", public)
+	}
+}
+
+func generateEphemeralKey() (*Key, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	return newKey(fmt.Sprintf("ephemeral-%x", id), private, public)
+}
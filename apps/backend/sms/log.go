@@ -0,0 +1,19 @@
+package sms
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// LogSender prints messages instead of delivering them, for local development
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (s *LogSender) Send(ctx context.Context, to string, body string) error {
+	log.Infof("SMS to %s: %s", to, body)
+	return nil
+}
@@ -0,0 +1,35 @@
+package sms
+
+import (
+	"context"
+	"sync"
+)
+
+type Message struct {
+	To   string
+	Body string
+}
+
+// MemorySender keeps sent messages in memory for tests
+type MemorySender struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+func (s *MemorySender) Send(ctx context.Context, to string, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, Message{To: to, Body: body})
+	return nil
+}
+
+// Sent returns a copy of every message delivered so far
+func (s *MemorySender) Sent() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.sent...)
+}
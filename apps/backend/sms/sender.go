@@ -0,0 +1,20 @@
+package sms
+
+import (
+	"fmt"
+
+	"github.com/montekkundan/bored/backend/config"
+	"github.com/montekkundan/bored/backend/models"
+)
+
+// NewSender picks the gateway configured through SMS_SENDER
+func NewSender(config *config.EnvConfig) (models.SMSSender, error) {
+	switch config.SMSSender {
+	case "log", "":
+		return NewLogSender(), nil
+	case "memory":
+		return NewMemorySender(), nil
+	default:
+		return nil, fmt.Errorf("unknown sms sender %q", config.SMSSender)
+	}
+}
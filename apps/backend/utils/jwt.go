@@ -1,36 +1,128 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func GenerateJWT(claims jwt.Claims, method jwt.SigningMethod, jwtSecret string) (string, error) {
-	return jwt.NewWithClaims(method, claims).SignedString([]byte(jwtSecret))
+const (
+	AccessTokenType  = "access"
+	RefreshTokenType = "refresh"
+)
+
+// TokenSigner signs and verifies JWTs, see signing.KeyManager
+type TokenSigner interface {
+	Sign(claims jwt.Claims) (string, error)
+	Parse(tokenStr string, claims jwt.Claims) (*jwt.Token, error)
 }
 
-func GenerateAccessToken(userID uint, roles []string, tokenVersion int, jwtSecret string, expiryMinutes int) (string, error) {
+func GenerateAccessToken(signer TokenSigner, userID uint, roles []string, tokenVersion int, sessionID string, expiryMinutes int) (string, error) {
+	// jti identifies the token for blacklisting
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
 	claims := jwt.MapClaims{
 		"id":      userID,
+		"jti":     jti,
+		"sid":     sessionID,
+		"typ":     AccessTokenType,
 		"roles":   roles,
 		"version": tokenVersion,
 		"exp":     time.Now().Add(time.Minute * time.Duration(expiryMinutes)).Unix(),
 	}
-	return GenerateJWT(claims, jwt.SigningMethodHS256, jwtSecret)
+	return signer.Sign(claims)
 }
 
-func GenerateRefreshToken(userID uint, tokenVersion int, jwtSecret string, expiryDays int) (string, error) {
+// GenerateImpersonationToken mints an access token for userID that also names the admin acting as them.
+// The "act" claim carries the admin's ID and token version so logging the admin out ends the impersonation too.
+func GenerateImpersonationToken(signer TokenSigner, userID uint, roles []string, tokenVersion int, sessionID string, actorID uint, actorTokenVersion int, expiryMinutes int) (string, error) {
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
 	claims := jwt.MapClaims{
 		"id":      userID,
+		"jti":     jti,
+		"sid":     sessionID,
+		"typ":     AccessTokenType,
+		"roles":   roles,
+		"version": tokenVersion,
+		"act": map[string]interface{}{
+			"id":      actorID,
+			"version": actorTokenVersion,
+		},
+		"exp": time.Now().Add(time.Minute * time.Duration(expiryMinutes)).Unix(),
+	}
+	return signer.Sign(claims)
+}
+
+// Impersonator returns the admin ID and token version from an impersonation token's "act" claim
+func Impersonator(claims jwt.MapClaims) (uint, int, bool) {
+	act, ok := claims["act"].(map[string]interface{})
+	if !ok {
+		return 0, 0, false
+	}
+
+	id, ok := act["id"].(float64)
+	if !ok {
+		return 0, 0, false
+	}
+	version, ok := act["version"].(float64)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint(id), int(version), true
+}
+
+func GenerateRefreshToken(signer TokenSigner, userID uint, tokenVersion int, expiryDays int) (string, error) {
+	// jti keeps tokens minted in the same second distinct
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{
+		"id":      userID,
+		"jti":     jti,
+		"typ":     RefreshTokenType,
 		"version": tokenVersion,
 		"exp":     time.Now().Add(time.Hour * 24 * time.Duration(expiryDays)).Unix(),
 	}
-	return GenerateJWT(claims, jwt.SigningMethodHS256, jwtSecret)
+	return signer.Sign(claims)
+}
+
+// ParseToken verifies tokenStr and checks it was issued as tokenType, so a refresh token
+// can never be presented as an access token or the other way around
+func ParseToken(signer TokenSigner, tokenStr string, tokenType string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+
+	token, err := signer.Parse(tokenStr, claims)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if typ, _ := claims["typ"].(string); typ != tokenType {
+		return nil, fmt.Errorf("expected a %s token", tokenType)
+	}
+
+	return claims, nil
+}
+
+// AccessTokenBlacklistKey is the Redis key marking an access token's jti as revoked
+func AccessTokenBlacklistKey(jti string) string {
+	return fmt.Sprintf("blacklist:%s", jti)
 }
 
-func ParseToken(tokenStr string, jwtSecret string) (*jwt.Token, error) {
-	return jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtSecret), nil
-	})
+// SessionRevokedKey is the Redis key marking every access token of a session as revoked
+func SessionRevokedKey(sessionID string) string {
+	return fmt.Sprintf("session-revoked:%s", sessionID)
 }
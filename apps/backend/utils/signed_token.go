@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSignedToken = errors.New("invalid or expired token")
+
+// SignedToken is the decoded payload of a token produced by GenerateSignedToken
+type SignedToken struct {
+	Purpose   string
+	UserID    uint
+	ExpiresAt time.Time
+	Nonce     string
+}
+
+// GenerateSignedToken returns an HMAC-signed token binding a user, a purpose and an expiry.
+// The random nonce lets the caller store a hash and enforce single use.
+func GenerateSignedToken(purpose string, userID uint, ttl time.Duration, secret string) (string, *SignedToken, error) {
+	nonce, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", nil, err
+	}
+
+	claims := &SignedToken{
+		Purpose:   purpose,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(ttl),
+		Nonce:     nonce,
+	}
+
+	payload := fmt.Sprintf("%s.%d.%d.%s", claims.Purpose, claims.UserID, claims.ExpiresAt.Unix(), claims.Nonce)
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+
+	return encoded + "." + signPayload(encoded, secret), claims, nil
+}
+
+// ParseSignedToken checks the signature, purpose and expiry of a signed token
+func ParseSignedToken(token, purpose, secret string) (*SignedToken, error) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(signPayload(encoded, secret))) {
+		return nil, ErrInvalidSignedToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidSignedToken
+	}
+
+	parts := strings.Split(string(payload), ".")
+	if len(parts) != 4 || parts[0] != purpose {
+		return nil, ErrInvalidSignedToken
+	}
+
+	userID, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return nil, ErrInvalidSignedToken
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return nil, ErrInvalidSignedToken
+	}
+
+	return &SignedToken{
+		Purpose:   parts[0],
+		UserID:    uint(userID),
+		ExpiresAt: time.Unix(expiresAt, 0),
+		Nonce:     parts[3],
+	}, nil
+}
+
+func signPayload(payload, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
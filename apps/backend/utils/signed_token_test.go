@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTokenSecret = "test-secret"
+
+func TestSignedTokenRoundTrip(t *testing.T) {
+	token, issued, err := GenerateSignedToken("verify_email", 42, time.Hour, testTokenSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseSignedToken(token, "verify_email", testTokenSecret)
+	if err != nil {
+		t.Fatalf("ParseSignedToken: %v", err)
+	}
+
+	if parsed.Purpose != "verify_email" || parsed.UserID != 42 || parsed.Nonce != issued.Nonce {
+		t.Errorf("ParseSignedToken = %+v, want purpose verify_email, user 42 and nonce %s", parsed, issued.Nonce)
+	}
+	if parsed.ExpiresAt.Unix() != issued.ExpiresAt.Unix() {
+		t.Errorf("ExpiresAt = %v, want %v", parsed.ExpiresAt, issued.ExpiresAt)
+	}
+}
+
+func TestSignedTokensAreUnique(t *testing.T) {
+	first, _, err := GenerateSignedToken("verify_email", 42, time.Hour, testTokenSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := GenerateSignedToken("verify_email", 42, time.Hour, testTokenSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Error("two tokens for the same user and purpose are identical")
+	}
+}
+
+func TestParseSignedTokenRejects(t *testing.T) {
+	valid, _, err := GenerateSignedToken("password_reset", 7, time.Hour, testTokenSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _, err := GenerateSignedToken("password_reset", 7, -time.Minute, testTokenSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, signature, _ := strings.Cut(valid, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Same signature over a payload naming another user
+	forgedPayload := strings.Replace(string(payload), ".7.", ".8.", 1)
+	forged := base64.RawURLEncoding.EncodeToString([]byte(forgedPayload)) + "." + signature
+
+	tampered := []byte(signature)
+	if tampered[0] == 'A' {
+		tampered[0] = 'B'
+	} else {
+		tampered[0] = 'A'
+	}
+
+	// Correctly signed, but not in the payload format
+	malformedPayload := base64.RawURLEncoding.EncodeToString([]byte("password_reset.7"))
+	malformed := malformedPayload + "." + signPayload(malformedPayload, testTokenSecret)
+
+	// Correctly signed, with a user ID that doesn't parse
+	badUserPayload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("password_reset.x.%d.nonce", time.Now().Add(time.Hour).Unix())))
+	badUser := badUserPayload + "." + signPayload(badUserPayload, testTokenSecret)
+
+	tests := []struct {
+		name    string
+		token   string
+		purpose string
+		secret  string
+	}{
+		{"wrong purpose", valid, "verify_email", testTokenSecret},
+		{"wrong secret", valid, "password_reset", "other-secret"},
+		{"expired", expired, "password_reset", testTokenSecret},
+		{"forged payload", forged, "password_reset", testTokenSecret},
+		{"tampered signature", encoded + "." + string(tampered), "password_reset", testTokenSecret},
+		{"missing signature", encoded, "password_reset", testTokenSecret},
+		{"empty", "", "password_reset", testTokenSecret},
+		{"malformed payload", malformed, "password_reset", testTokenSecret},
+		{"unparseable user", badUser, "password_reset", testTokenSecret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseSignedToken(tt.token, tt.purpose, tt.secret); !errors.Is(err, ErrInvalidSignedToken) {
+				t.Errorf("ParseSignedToken error = %v, want ErrInvalidSignedToken", err)
+			}
+		})
+	}
+}
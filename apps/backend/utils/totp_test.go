@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// Base32 of the RFC 6238 SHA-1 test secret "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTPCodeMatchesRFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes, these are their last 6 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		code, err := GenerateTOTPCode(rfcSecret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("GenerateTOTPCode at %d: %v", tt.unix, err)
+		}
+		if code != tt.code {
+			t.Errorf("GenerateTOTPCode at %d = %s, want %s", tt.unix, code, tt.code)
+		}
+	}
+}
+
+func TestGenerateTOTPCodeAcceptsLowercaseSecrets(t *testing.T) {
+	code, err := GenerateTOTPCode(" gezdgnbvgy3tqojqgezdgnbvgy3tqojq ", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "287082" {
+		t.Errorf("GenerateTOTPCode = %s, want 287082", code)
+	}
+}
+
+func TestGenerateTOTPCodeRejectsInvalidSecrets(t *testing.T) {
+	if _, err := GenerateTOTPCode("not base32!", 1); err == nil {
+		t.Error("GenerateTOTPCode accepted an invalid secret")
+	}
+}
+
+func TestTOTPStep(t *testing.T) {
+	tests := []struct {
+		unix int64
+		step int64
+	}{
+		{0, 0},
+		{29, 0},
+		{30, 1},
+		{59, 1},
+		{60, 2},
+	}
+
+	for _, tt := range tests {
+		if step := TOTPStep(time.Unix(tt.unix, 0)); step != tt.step {
+			t.Errorf("TOTPStep(%d) = %d, want %d", tt.unix, step, tt.step)
+		}
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+
+	codeAt := func(step int64) string {
+		code, err := GenerateTOTPCode(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		skew   int64
+		step   int64
+		ok     bool
+	}{
+		{"current step", rfcSecret, codeAt(current), 1, current, true},
+		{"previous step within skew", rfcSecret, codeAt(current - 1), 1, current - 1, true},
+		{"next step within skew", rfcSecret, codeAt(current + 1), 1, current + 1, true},
+		{"two steps behind", rfcSecret, codeAt(current - 2), 1, 0, false},
+		{"two steps ahead", rfcSecret, codeAt(current + 2), 1, 0, false},
+		{"previous step without skew", rfcSecret, codeAt(current - 1), 0, 0, false},
+		{"surrounding whitespace", rfcSecret, " " + codeAt(current) + "\n", 1, current, true},
+		{"too short", rfcSecret, codeAt(current)[:5], 1, 0, false},
+		{"too long", rfcSecret, codeAt(current) + "0", 1, 0, false},
+		{"empty", rfcSecret, "", 1, 0, false},
+		{"invalid secret", "not base32!", codeAt(current), 1, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTPCode(tt.secret, tt.code, now, tt.skew)
+			if ok != tt.ok || step != tt.step {
+				t.Errorf("ValidateTOTPCode = (%d, %v), want (%d, %v)", step, ok, tt.step, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecretRoundTrips(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("secret has %d characters, want 32 for 160 bits", len(secret))
+	}
+
+	now := time.Now()
+	code, err := GenerateTOTPCode(secret, TOTPStep(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ValidateTOTPCode(secret, code, now, 0); !ok {
+		t.Error("a freshly generated code did not validate")
+	}
+}